@@ -0,0 +1,169 @@
+package msp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// armorType is the type of the PEM blocks that shares are armored in.
+const armorType = "MSP SHARE"
+
+// An ArmoredShare is a share envelope along with the human-readable context
+// that's written into the headers of its PEM block.
+type ArmoredShare struct {
+	Holder    string
+	Predicate string
+	Envelope  Envelope
+}
+
+// Armor encodes the share as a PEM block.  The headers repeat the holder,
+// predicate, split ID and index so that people can tell shares apart, and
+// include a checksum of the envelope to catch transcription errors.
+func (as ArmoredShare) Armor() ([]byte, error) {
+	if strings.ContainsAny(as.Holder, "\r\n") || strings.ContainsAny(as.Predicate, "\r\n") {
+		return nil, errors.New("Armor headers can't contain newlines.")
+	}
+
+	env, err := as.Envelope.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type: armorType,
+		Headers: map[string]string{
+			"Holder":    as.Holder,
+			"Predicate": as.Predicate,
+			"Split-ID":  hex.EncodeToString(as.Envelope.SplitID[:]),
+			"Index":     strconv.Itoa(int(as.Envelope.Index)),
+			"Checksum":  armorChecksum(env),
+		},
+		Bytes: env,
+	}), nil
+}
+
+// ArmorShares armors the marshalled envelopes returned by DistributeEnvelopes,
+// returning one text file's worth of PEM blocks for each holder.
+func (m MSP) ArmorShares(shares map[string][][]byte) (map[string][]byte, error) {
+	out := make(map[string][]byte, len(shares))
+
+	for name, userShares := range shares {
+		buf := &bytes.Buffer{}
+
+		for _, b := range userShares {
+			e, err := UnmarshalEnvelope(b)
+			if err != nil {
+				return nil, err
+			}
+
+			block, err := ArmoredShare{name, Formatted(m).String(), e}.Armor()
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(block)
+		}
+
+		out[name] = buf.Bytes()
+	}
+
+	return out, nil
+}
+
+// Dearmor decodes every armored share in data, in order.  It's tolerant of the
+// kinds of damage text goes through when it's stored in a password manager or
+// typed in from paper:  indentation, blank lines, CRLF line endings and
+// re-wrapped base64 are all fine.  The checksum, and the split ID and index
+// headers, must match the envelope.
+func Dearmor(data []byte) ([]ArmoredShare, error) {
+	var (
+		out     []ArmoredShare
+		inBlock bool
+		headers map[string]string
+		body    strings.Builder
+	)
+
+	begin, end := "-----BEGIN "+armorType+"-----", "-----END "+armorType+"-----"
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == begin:
+			if inBlock {
+				return nil, errors.New("Armored share isn't terminated.")
+			}
+			inBlock, headers = true, make(map[string]string)
+			body.Reset()
+
+		case line == end:
+			if !inBlock {
+				return nil, errors.New("Armored share end without a beginning.")
+			}
+			inBlock = false
+
+			as, err := dearmorBlock(headers, body.String())
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, as)
+
+		case !inBlock || line == "":
+
+		case body.Len() == 0 && strings.Contains(line, ":"):
+			kv := strings.SplitN(line, ":", 2)
+			headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+
+		default:
+			body.WriteString(strings.Join(strings.Fields(line), ""))
+		}
+	}
+
+	if inBlock {
+		return nil, errors.New("Armored share isn't terminated.")
+	} else if len(out) == 0 {
+		return nil, errors.New("No armored shares found.")
+	}
+
+	return out, nil
+}
+
+func dearmorBlock(headers map[string]string, body string) (as ArmoredShare, err error) {
+	env, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return as, fmt.Errorf("Armored share is corrupt: %v", err)
+	}
+
+	if sum, ok := headers["Checksum"]; !ok {
+		return as, errors.New("Armored share has no checksum.")
+	} else if !strings.EqualFold(sum, armorChecksum(env)) {
+		return as, errors.New("Armored share's checksum doesn't match.")
+	}
+
+	as.Holder, as.Predicate = headers["Holder"], headers["Predicate"]
+	if as.Envelope, err = UnmarshalEnvelope(env); err != nil {
+		return as, err
+	}
+
+	if id, ok := headers["Split-ID"]; ok && !strings.EqualFold(id, hex.EncodeToString(as.Envelope.SplitID[:])) {
+		return as, errors.New("Armored share's split ID header doesn't match its envelope.")
+	}
+	if index, ok := headers["Index"]; ok && index != strconv.Itoa(int(as.Envelope.Index)) {
+		return as, errors.New("Armored share's index header doesn't match its envelope.")
+	}
+
+	return as, nil
+}
+
+// armorChecksum returns the first four bytes of the SHA-256 hash of an
+// envelope, in hex.
+func armorChecksum(env []byte) string {
+	sum := sha256.Sum256(env)
+	return hex.EncodeToString(sum[:4])
+}
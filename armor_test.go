@@ -0,0 +1,106 @@
+package msp
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestArmor(t *testing.T) {
+	db := &Database{
+		"Alice": [][]byte{},
+		"Bob":   [][]byte{},
+		"Carl":  [][]byte{},
+	}
+
+	sec := []byte("attack at dawn")
+	predicate, _ := StringToMSP("(2, (1, Alice, Bob), (2, Alice, Carl), Carl)")
+
+	shares, err := predicate.DistributeEnvelopes(nil, sec, db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	armored, err := predicate.ArmorShares(shares)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(armored["Alice"]), "Holder: Alice") ||
+		!strings.Contains(string(armored["Alice"]), "Predicate: (2, (1, Alice, Bob), (2, Alice, Carl), Carl)") {
+		t.Fatalf("Headers are missing:\n%s", armored["Alice"])
+	}
+
+	// Mangle the text:  indent it, use CRLF, and re-wrap the base64.
+	mangled := strings.Replace(string(armored["Alice"]), "\n", "\r\n    ", -1)
+	mangled = strings.Replace(mangled, "Checksum", "\r\n\r\nChecksum", -1)
+	mangled = strings.Replace(mangled, "AAA", "A A\r\n A", -1)
+
+	recovered := Database{}
+	for name, text := range map[string]string{"Alice": mangled, "Carl": string(armored["Carl"])} {
+		decoded, err := Dearmor([]byte(text))
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+
+		for _, as := range decoded {
+			if as.Holder != name {
+				t.Fatalf("Wrong holder: %v", as.Holder)
+			}
+			b, err := as.Envelope.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			recovered[name] = append(recovered[name], b)
+		}
+	}
+
+	if !reflect.DeepEqual(recovered["Alice"], shares["Alice"]) {
+		t.Fatalf("Alice's shares changed after armoring!")
+	}
+
+	out, err := predicate.RecoverSecretFromEnvelopes(&recovered, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(sec, out) {
+		t.Fatalf("Secrets derived differed:  %x %x", sec, out)
+	}
+}
+
+func TestArmorCorrupt(t *testing.T) {
+	as := ArmoredShare{"Alice", "(1, Alice, Bob)", Envelope{Version: EnvelopeVersion, Field: FieldGF256, Share: []byte("share")}}
+
+	block, err := as.Armor()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(string(block), "\n")
+	body := len(lines) - 3 // The last line of base64.
+
+	// Change one character of the body.
+	corrupt := append([]string{}, lines...)
+	if corrupt[body][0] == 'A' {
+		corrupt[body] = "B" + corrupt[body][1:]
+	} else {
+		corrupt[body] = "A" + corrupt[body][1:]
+	}
+
+	bad := []string{
+		strings.Join(corrupt, "\n"),
+		strings.Replace(string(block), "Index: 0", "Index: 1", 1),
+		strings.Join(lines[:len(lines)-2], "\n"),
+		"no shares here",
+	}
+
+	for _, text := range bad {
+		if _, err := Dearmor([]byte(text)); err == nil {
+			t.Fatalf("Corrupt armor was accepted:\n%v", text)
+		}
+	}
+
+	if _, err := (ArmoredShare{Holder: "Alice\nBob"}).Armor(); err == nil {
+		t.Fatalf("Newline in a header was accepted!")
+	}
+}
@@ -0,0 +1,116 @@
+package msp
+
+import (
+	"io"
+	"math"
+)
+
+// BinaryField is GF(2^n), where n is eight times the length of the slice.  The
+// slice holds the low terms of the modulus, x^n + m(x), as a little-endian
+// polynomial.  Elements are also little-endian polynomials, and arithmetic on
+// them is constant-time.
+type BinaryField []byte
+
+func BuildField(size int, modulus ...byte) BinaryField {
+	field := make(BinaryField, size)
+	copy(field, modulus)
+
+	return field
+}
+
+func (f BinaryField) Elem(val []byte) Elem {
+	elem := Elem{
+		Field: f,
+		e:     make([]byte, len(f)),
+	}
+	copy(elem.e, val)
+
+	return elem
+}
+
+func (f BinaryField) Row(width int) Row {
+	return newRow(f, width)
+}
+
+func (f BinaryField) Matrix(height, width int) Matrix {
+	return newMatrix(f, height, width)
+}
+
+func (f BinaryField) Zero() Elem {
+	return f.Elem(nil)
+}
+
+func (f BinaryField) One() Elem {
+	return f.Elem([]byte{1})
+}
+
+func (f BinaryField) Size() int {
+	return len(f)
+}
+
+func (f BinaryField) BitSize() int {
+	return f.Size() * 8
+}
+
+// Point encodes i+1 little-endian across the whole element.
+func (f BinaryField) Point(i int) Elem {
+	elem := f.Zero()
+
+	for j, x := 0, uint64(i+1); j < f.Size() && x > 0; j, x = j+1, x>>8 {
+		elem.e[j] = byte(x)
+	}
+
+	return elem
+}
+
+func (f BinaryField) MaxPoints() int {
+	if f.BitSize() >= 63 {
+		return math.MaxInt
+	}
+
+	return 1<<uint(f.BitSize()) - 1
+}
+
+func (f BinaryField) Vandermonde(points []int, width int) Matrix {
+	return newVandermonde(f, points, width)
+}
+
+// Addition and subtraction are both XOR.
+func (f BinaryField) add(dst, a, b []byte) {
+	for i := range dst {
+		dst[i] = a[i] ^ b[i]
+	}
+}
+
+func (f BinaryField) sub(dst, a, b []byte) {
+	f.add(dst, a, b)
+}
+
+func (f BinaryField) mul(dst, a, b []byte) {
+	t := f.table()
+	f.fromWords(t.mul(f.toWords(a), f.toWords(b)), dst)
+}
+
+// invert computes a^(2^n - 2) with a fixed sequence of multiplications.  The
+// inverse of 0 is 0.
+func (f BinaryField) invert(dst, a []byte) {
+	t := f.table()
+	elem, temp := f.toWords(a), f.toWords(a)
+
+	rounds := f.BitSize() - 2
+	for i := 0; i < rounds; i++ {
+		temp = t.mul(temp, temp)
+		elem = t.mul(elem, temp)
+	}
+
+	f.fromWords(t.mul(elem, elem), dst)
+}
+
+func (f BinaryField) random(r io.Reader) ([]byte, error) {
+	buf := make([]byte, f.Size())
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
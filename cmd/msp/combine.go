@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Bren2010/msp"
+)
+
+type combineOutput struct {
+	Predicate string   `json:"predicate"`
+	SplitID   string   `json:"split_id"`
+	Users     []string `json:"users"`            // Users whose share files were found.
+	Secret    []byte   `json:"secret,omitempty"` // Base64, unless the secret was written to a file.
+}
+
+// shareDatabase is a user database of the shares read from share files.
+type shareDatabase map[string][][]byte
+
+func (sd shareDatabase) ValidUser(name string) bool {
+	_, ok := sd[name]
+	return ok
+}
+
+func (sd shareDatabase) CanGetShare(name string) bool {
+	_, ok := sd[name]
+	return ok
+}
+
+func (sd shareDatabase) GetShare(name string) ([][]byte, error) {
+	out, ok := sd[name]
+	if !ok {
+		return nil, errors.New("Not found!")
+	}
+
+	return out, nil
+}
+
+func combine(args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("combine", flag.ContinueOnError)
+	predicate := fs.String("predicate", "", "predicate the secret was split with (default from the share files)")
+	inDir := fs.String("in", "", "directory to read share files from")
+	outFile := fs.String("out", "", "file to write the secret to (default the JSON output)")
+	macKeyFile := fs.String("mac-key", "", "file holding the key to check share MACs with")
+
+	if err := parseFlags(fs, args); err != nil {
+		return nil, err
+	} else if *inDir == "" {
+		return nil, fail(exitUsage, errors.New("combine needs -in."))
+	}
+
+	macKey, err := readMACKey(*macKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	db, headerPredicate, err := readShares(*inDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if *predicate == "" {
+		*predicate = headerPredicate
+	}
+	m, err := msp.StringToMSP(*predicate)
+	if err != nil {
+		return nil, fail(exitPredicate, err)
+	}
+
+	out := combineOutput{Predicate: msp.Formatted(m).String()}
+	for name := range db {
+		out.Users = append(out.Users, name)
+	}
+	sort.Strings(out.Users)
+
+	if !msp.Formatted(m).Ok(db) {
+		return nil, fail(exitNotEnough, fmt.Errorf("Not enough shares to recover; found shares for %v.", strings.Join(out.Users, ", ")))
+	}
+
+	// The shares are already in memory and satisfy the predicate, so anything
+	// that goes wrong now is wrong with their contents.
+	sec, err := m.RecoverSecretFromEnvelopes(db, macKey)
+	if err != nil {
+		return nil, fail(exitShares, err)
+	}
+
+	e, _ := msp.UnmarshalEnvelope(db[out.Users[0]][0])
+	out.SplitID = hex.EncodeToString(e.SplitID[:])
+
+	if *outFile == "" {
+		out.Secret = sec
+	} else if err := writeNew(*outFile, sec); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// readShares reads every share file in a directory, returning the envelopes of
+// each holder in order, and the predicate named in the files' headers.
+func readShares(dir string) (db shareDatabase, predicate string, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	byIndex := make(map[string]map[uint32][]byte)
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".pem" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, "", err
+		}
+
+		shares, err := msp.Dearmor(data)
+		if err != nil {
+			return nil, "", fail(exitShares, fmt.Errorf("%v: %v", entry.Name(), err))
+		}
+
+		for _, as := range shares {
+			if as.Holder == "" {
+				return nil, "", fail(exitShares, fmt.Errorf("%v: Share has no holder.", entry.Name()))
+			} else if predicate == "" {
+				predicate = as.Predicate
+			} else if as.Predicate != predicate {
+				return nil, "", fail(exitShares, fmt.Errorf("%v: Share is for a different predicate.", entry.Name()))
+			}
+
+			if byIndex[as.Holder] == nil {
+				byIndex[as.Holder] = make(map[uint32][]byte)
+			} else if _, dup := byIndex[as.Holder][as.Envelope.Index]; dup {
+				return nil, "", fail(exitShares, fmt.Errorf("%v: Share %v of %v was already read.", entry.Name(), as.Envelope.Index, as.Holder))
+			}
+			if byIndex[as.Holder][as.Envelope.Index], err = as.Envelope.Marshal(); err != nil {
+				return nil, "", fail(exitShares, fmt.Errorf("%v: %v", entry.Name(), err))
+			}
+		}
+	}
+
+	if len(byIndex) == 0 {
+		return nil, "", fail(exitNotEnough, errors.New("No share files found."))
+	}
+
+	db = make(shareDatabase)
+	for name, shares := range byIndex {
+		for i := uint32(0); i < uint32(len(shares)); i++ {
+			share, ok := shares[i]
+			if !ok {
+				return nil, "", fail(exitShares, fmt.Errorf("Share %v of %v is missing.", i, name))
+			}
+
+			db[name] = append(db[name], share)
+		}
+	}
+
+	return db, predicate, nil
+}
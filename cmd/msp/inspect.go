@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"strings"
+
+	"github.com/Bren2010/msp"
+)
+
+type inspectOutput struct {
+	Formatted   string         `json:"formatted"`            // As split uses it, and writes in share files.
+	Compressed  string         `json:"compressed,omitempty"` // Only for display, if compression changes it.
+	Users       map[string]int `json:"users"`     // Number of shares each user gets.
+	MinimalSets [][]string     `json:"minimal_sets,omitempty"`
+	TooMany     bool           `json:"too_many_sets,omitempty"` // Set instead of minimal_sets if there are more than -limit.
+	DerivePath  *derivePath    `json:"derive_path,omitempty"`
+}
+
+// derivePath is the output of MSP.DerivePath for the users given with -present.
+type derivePath struct {
+	Present []string `json:"present"`
+	Ok      bool     `json:"ok"`
+	Names   []string `json:"names"`
+	Locs    []int    `json:"locs"`
+	Trace   []string `json:"trace"`
+}
+
+func inspect(args []string) (interface{}, error) {
+	fs := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	predicate := fs.String("predicate", "", "predicate to inspect")
+	present := fs.String("present", "", "comma-separated users to run DerivePath against")
+	limit := fs.Int("limit", 1000, "maximum number of minimal authorized sets to list")
+
+	if err := parseFlags(fs, args); err != nil {
+		return nil, err
+	} else if *predicate == "" {
+		return nil, fail(exitUsage, errors.New("inspect needs -predicate."))
+	}
+
+	// Everything is shown for the predicate exactly as split parses it, so the
+	// paths and leaf order match the share files.
+	m, err := msp.StringToMSP(*predicate)
+	if err != nil {
+		return nil, fail(exitPredicate, err)
+	}
+	f := msp.Formatted(m)
+
+	out := inspectOutput{Formatted: f.String(), Users: f.Users()}
+
+	// The compressed form is parsed from a copy, since Compress works in place.
+	compressed, err := msp.StringToFormatted(f.String())
+	if err != nil {
+		return nil, err
+	}
+	compressed.Compress()
+	if compressed.String() != out.Formatted {
+		out.Compressed = compressed.String()
+	}
+	sets, ok := f.MinimalSets(*limit)
+	out.MinimalSets, out.TooMany = sets, !ok
+
+	if *present != "" {
+		db := make(shareDatabase)
+		dp := &derivePath{}
+
+		for _, name := range strings.Split(*present, ",") {
+			name = strings.TrimSpace(name)
+			db[name] = nil
+			dp.Present = append(dp.Present, name)
+		}
+
+		dp.Ok, dp.Names, dp.Locs, dp.Trace = msp.MSP(f).DerivePath(db)
+		out.DerivePath = dp
+	}
+
+	return out, nil
+}
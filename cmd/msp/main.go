@@ -0,0 +1,134 @@
+// Command msp splits a secret into share files according to a predicate, and
+// combines share files back into the secret.
+//
+//	msp split -predicate "(2, Alice, Bob, Carl)" -out shares/ [-in secret] [-mac-key file]
+//	msp combine -in shares/ [-predicate "..."] [-out secret] [-mac-key file]
+//	msp inspect -predicate "(2, Alice, Bob, Carl)" [-present Alice,Bob]
+//
+// split writes one file of armored share envelopes per user, named after the
+// user.  combine reads every .pem file in a directory and, unless it's given
+// one, takes the predicate from the files' headers.  If split is given a MAC
+// key, every envelope is signed with it, and combine checks the MACs when it's
+// given the same key.  inspect prints the predicate as split would use it,
+// how many shares each user gets, the minimal sets of users that can recover
+// the secret, and optionally the output of DerivePath when the given users are
+// present.  If compression would change the predicate, the compressed form is
+// printed too, but only for display:  split doesn't compress.  Each writes a JSON summary to stdout, and
+// errors as JSON to stderr.  The exit code says what went wrong; see the exit
+// constants below.
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	exitOK        = 0
+	exitFailure   = 1 // Anything not covered below, like I/O errors.
+	exitUsage     = 2 // Bad subcommand or flags.
+	exitPredicate = 3 // The predicate couldn't be parsed.
+	exitShares    = 4 // Share files are corrupt, or don't belong together.
+	exitNotEnough = 5 // The shares present don't satisfy the predicate.
+)
+
+// A cliError is an error with the exit code it should cause.
+type cliError struct {
+	code int
+	err  error
+}
+
+func (ce cliError) Error() string { return ce.err.Error() }
+
+func fail(code int, err error) error { return cliError{code, err} }
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr, rand.Reader))
+}
+
+// run executes the command with the given arguments and returns its exit code.
+// Randomness is read from random.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer, random io.Reader) int {
+	if len(args) == 0 {
+		return report(stderr, fail(exitUsage, errors.New("Usage: msp split|combine|inspect [flags]")))
+	}
+
+	var (
+		out interface{}
+		err error
+	)
+
+	switch args[0] {
+	case "split":
+		out, err = split(args[1:], stdin, random)
+	case "combine":
+		out, err = combine(args[1:])
+	case "inspect":
+		out, err = inspect(args[1:])
+	default:
+		err = fail(exitUsage, fmt.Errorf("Unknown subcommand %q.", args[0]))
+	}
+
+	if err != nil {
+		return report(stderr, err)
+	}
+
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return report(stderr, err)
+	}
+
+	return exitOK
+}
+
+// report writes an error to w as JSON, and returns its exit code.
+func report(w io.Writer, err error) int {
+	code := exitFailure
+	if ce, ok := err.(cliError); ok {
+		code = ce.code
+	}
+
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+		Code  int    `json:"code"`
+	}{err.Error(), code})
+
+	return code
+}
+
+// readMACKey reads the MAC key from the file named by a -mac-key flag, or
+// returns nil if the flag wasn't given.
+func readMACKey(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	key, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	} else if len(key) == 0 {
+		return nil, fail(exitUsage, errors.New("MAC key file is empty."))
+	}
+
+	return key, nil
+}
+
+// parseFlags parses a subcommand's flags, turning errors into usage errors.
+// The flag package's own messages are dropped, so that stderr is only JSON.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	fs.SetOutput(io.Discard)
+
+	if err := fs.Parse(args); err != nil {
+		return fail(exitUsage, err)
+	} else if fs.NArg() > 0 {
+		return fail(exitUsage, fmt.Errorf("Unexpected argument %q.", fs.Arg(0)))
+	}
+
+	return nil
+}
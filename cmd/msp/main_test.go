@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bren2010/msp"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// testReader is a deterministic source of randomness, so that split's output
+// can be compared against golden files.
+type testReader struct {
+	seed    string
+	counter uint64
+	buf     []byte
+}
+
+func (tr *testReader) Read(p []byte) (int, error) {
+	for len(tr.buf) < len(p) {
+		block := sha256.Sum256(binary.BigEndian.AppendUint64([]byte(tr.seed), tr.counter))
+		tr.buf = append(tr.buf, block[:]...)
+		tr.counter++
+	}
+
+	n := copy(p, tr.buf)
+	tr.buf = tr.buf[n:]
+
+	return n, nil
+}
+
+// golden compares got against the named file in testdata, or rewrites the file
+// if -update is set.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		} else if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(got, want) {
+		t.Fatalf("%v differs from golden file:\n%s\nwanted:\n%s", name, got, want)
+	}
+}
+
+// runTest runs the command, returning its exit code, stdout and stderr.
+func runTest(t *testing.T, stdin string, seed string, args ...string) (int, []byte, []byte) {
+	t.Helper()
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run(args, strings.NewReader(stdin), stdout, stderr, &testReader{seed: seed})
+
+	return code, stdout.Bytes(), stderr.Bytes()
+}
+
+const testPredicate = "(2, (1, Alice, Bob), (2, Alice, Dave), Carl)"
+
+func TestSplit(t *testing.T) {
+	dir := t.TempDir()
+
+	code, stdout, stderr := runTest(t, "attack at dawn\n", "split", "split", "-predicate", testPredicate, "-out", dir)
+	if code != exitOK {
+		t.Fatalf("Exit code %v: %s", code, stderr)
+	}
+	golden(t, "split.json", stdout)
+
+	for _, name := range []string{"Alice", "Bob", "Carl", "Dave"} {
+		file, err := os.ReadFile(filepath.Join(dir, name+".pem"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		golden(t, filepath.Join("shares", name+".pem"), file)
+	}
+
+	// Share files aren't overwritten.
+	if code, _, _ := runTest(t, "attack at dawn\n", "split", "split", "-predicate", testPredicate, "-out", dir); code != exitFailure {
+		t.Fatalf("Existing share files were overwritten: exit code %v", code)
+	}
+
+	// If one file can't be written, none are.
+	partial := t.TempDir()
+	os.WriteFile(filepath.Join(partial, "Dave.pem"), nil, 0600)
+
+	if code, _, _ := runTest(t, "attack at dawn\n", "split", "split", "-predicate", testPredicate, "-out", partial); code != exitFailure {
+		t.Fatalf("Existing share file was overwritten: exit code %v", code)
+	} else if entries, _ := os.ReadDir(partial); len(entries) != 1 {
+		t.Fatalf("Split was left half-written: %v files", len(entries))
+	}
+}
+
+func TestCombine(t *testing.T) {
+	code, stdout, stderr := runTest(t, "", "", "combine", "-in", filepath.Join("testdata", "shares"))
+	if code != exitOK {
+		t.Fatalf("Exit code %v: %s", code, stderr)
+	}
+	golden(t, "combine.json", stdout)
+
+	// Write the secret to a file instead.
+	out := filepath.Join(t.TempDir(), "secret")
+	if code, _, stderr := runTest(t, "", "", "combine", "-in", filepath.Join("testdata", "shares"), "-out", out); code != exitOK {
+		t.Fatalf("Exit code %v: %s", code, stderr)
+	}
+
+	if sec, err := os.ReadFile(out); err != nil {
+		t.Fatal(err)
+	} else if string(sec) != "attack at dawn\n" {
+		t.Fatalf("Wrong secret: %q", sec)
+	}
+}
+
+// copyShares copies the named share files from testdata into a new directory.
+func copyShares(t *testing.T, names ...string) string {
+	dir := t.TempDir()
+
+	for _, name := range names {
+		file, err := os.ReadFile(filepath.Join("testdata", "shares", name+".pem"))
+		if err != nil {
+			t.Fatal(err)
+		} else if err := os.WriteFile(filepath.Join(dir, name+".pem"), file, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+// rewriteShare changes the envelope of every share in a share file, and armors
+// it again with a matching checksum.
+func rewriteShare(t *testing.T, path string, change func(e *msp.Envelope)) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shares, err := msp.Dearmor(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := []byte{}
+	for _, as := range shares {
+		change(&as.Envelope)
+
+		block, err := as.Armor()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, block...)
+	}
+
+	if err := os.WriteFile(path, out, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	// Carl's share from a different split of the same secret.
+	otherSplit := t.TempDir()
+	if code, _, stderr := runTest(t, "attack at dawn\n", "other", "split", "-predicate", testPredicate, "-out", otherSplit); code != exitOK {
+		t.Fatalf("Exit code %v: %s", code, stderr)
+	}
+	mixed := copyShares(t, "Alice")
+	if err := os.Rename(filepath.Join(otherSplit, "Carl.pem"), filepath.Join(mixed, "Carl.pem")); err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt := copyShares(t, "Alice", "Carl")
+	file, _ := os.ReadFile(filepath.Join(corrupt, "Carl.pem"))
+	file = bytes.Replace(file, []byte("Index: 0"), []byte("Index: 1"), 1)
+	os.WriteFile(filepath.Join(corrupt, "Carl.pem"), file, 0600)
+
+	// Carl's share file, twice.
+	duplicate := copyShares(t, "Alice", "Carl")
+	file, _ = os.ReadFile(filepath.Join(duplicate, "Carl.pem"))
+	os.WriteFile(filepath.Join(duplicate, "Carl copy.pem"), file, 0600)
+
+	// A share one byte short.
+	short := copyShares(t, "Alice", "Carl")
+	rewriteShare(t, filepath.Join(short, "Carl.pem"), func(e *msp.Envelope) { e.Share = e.Share[:len(e.Share)-1] })
+
+	// Signed shares, one with a bad MAC.
+	keyFile, otherKeyFile := filepath.Join(t.TempDir(), "key"), filepath.Join(t.TempDir(), "key")
+	os.WriteFile(keyFile, []byte("mac key"), 0600)
+	os.WriteFile(otherKeyFile, []byte("other mac key"), 0600)
+
+	signed := t.TempDir()
+	if code, _, stderr := runTest(t, "attack at dawn\n", "signed", "split", "-predicate", testPredicate, "-out", signed, "-mac-key", keyFile); code != exitOK {
+		t.Fatalf("Exit code %v: %s", code, stderr)
+	} else if code, _, stderr := runTest(t, "", "", "combine", "-in", signed, "-mac-key", keyFile); code != exitOK {
+		t.Fatalf("Exit code %v: %s", code, stderr)
+	}
+
+	tampered := t.TempDir()
+	for _, name := range []string{"Alice", "Carl"} {
+		os.Rename(filepath.Join(signed, name+".pem"), filepath.Join(tampered, name+".pem"))
+	}
+	rewriteShare(t, filepath.Join(tampered, "Carl.pem"), func(e *msp.Envelope) { e.MAC[0] ^= 1 })
+
+	tests := []struct {
+		args []string
+		code int
+	}{
+		{[]string{}, exitUsage},
+		{[]string{"frobnicate"}, exitUsage},
+		{[]string{"split", "-predicate", testPredicate}, exitUsage},
+		{[]string{"split", "-bogus"}, exitUsage},
+		{[]string{"split", "-predicate", "(2, Alice", "-out", t.TempDir()}, exitPredicate},
+		{[]string{"combine", "-in", copyShares(t, "Alice", "Carl"), "-predicate", "(2, Alice"}, exitPredicate},
+		{[]string{"combine", "-in", copyShares(t, "Bob", "Dave")}, exitNotEnough},
+		{[]string{"combine", "-in", t.TempDir()}, exitNotEnough},
+		{[]string{"combine", "-in", corrupt}, exitShares},
+		{[]string{"combine", "-in", mixed}, exitShares},
+		{[]string{"combine", "-in", short}, exitShares},
+		{[]string{"combine", "-in", duplicate}, exitShares},
+		{[]string{"combine", "-in", tampered, "-mac-key", keyFile}, exitShares},
+		{[]string{"combine", "-in", copyShares(t, "Alice", "Carl"), "-mac-key", otherKeyFile}, exitShares},
+		{[]string{"combine", "-in", filepath.Join(t.TempDir(), "missing")}, exitFailure},
+	}
+
+	for _, test := range tests {
+		code, stdout, stderr := runTest(t, "secret", "", test.args...)
+		if code != test.code {
+			t.Fatalf("%v: exit code %v, wanted %v: %s", test.args, code, test.code, stderr)
+		} else if len(stdout) != 0 || !bytes.HasPrefix(stderr, []byte(`{"error":`)) {
+			t.Fatalf("%v: error wasn't reported as JSON:\n%s", test.args, stderr)
+		}
+	}
+
+	_, _, stderr := runTest(t, "", "", "combine", "-in", copyShares(t, "Bob", "Dave"))
+	golden(t, "not_enough.json", stderr)
+}
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		golden string
+		args   []string
+	}{
+		{"inspect.json", []string{"-predicate", "(2, (1, (1, Bob, Carl), Alice), (2, Alice, Dave), Carl)"}},
+		{"inspect_raw.json", []string{"-predicate", "(Alice | Bob) & Carl & Dave", "-present", "Bob, Carl,Dave"}},
+		{"inspect_compressed.json", []string{"-predicate", "(2, (2, Bob, Carl), Alice)", "-present", "Alice,Bob,Carl"}},
+		{"inspect_too_many.json", []string{"-predicate", "(3, Alice, Bob, Carl, Dave, Eve)", "-limit", "5", "-present", "Alice"}},
+	}
+
+	for _, test := range tests {
+		code, stdout, stderr := runTest(t, "", "", append([]string{"inspect"}, test.args...)...)
+		if code != exitOK {
+			t.Fatalf("%v: exit code %v: %s", test.args, code, stderr)
+		}
+		golden(t, test.golden, stdout)
+	}
+
+	if code, _, _ := runTest(t, "", "", "inspect", "-predicate", "(2, Alice, (1, Bob"); code != exitPredicate {
+		t.Fatalf("Bad predicate wasn't rejected: exit code %v", code)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Bren2010/msp"
+)
+
+type splitOutput struct {
+	Predicate string         `json:"predicate"`
+	SplitID   string         `json:"split_id"`
+	Users     map[string]int `json:"users"` // Number of shares in each user's file.
+}
+
+// anyone is a user database where every name is valid, so that a secret can be
+// split to whoever's in the predicate.
+type anyone struct{}
+
+func (anyone) ValidUser(name string) bool   { return true }
+func (anyone) CanGetShare(name string) bool { return false }
+func (anyone) GetShare(name string) ([][]byte, error) {
+	return nil, errors.New("Not implemented.")
+}
+
+func split(args []string, stdin io.Reader, random io.Reader) (interface{}, error) {
+	fs := flag.NewFlagSet("split", flag.ContinueOnError)
+	predicate := fs.String("predicate", "", "predicate to split the secret with")
+	in := fs.String("in", "", "file to read the secret from (default stdin)")
+	outDir := fs.String("out", "", "directory to write share files to")
+	macKeyFile := fs.String("mac-key", "", "file holding a key to sign share envelopes with")
+
+	if err := parseFlags(fs, args); err != nil {
+		return nil, err
+	} else if *predicate == "" || *outDir == "" {
+		return nil, fail(exitUsage, errors.New("split needs -predicate and -out."))
+	}
+
+	m, err := msp.StringToMSP(*predicate)
+	if err != nil {
+		return nil, fail(exitPredicate, err)
+	}
+
+	macKey, err := readMACKey(*macKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var sec []byte
+	if *in == "" {
+		sec, err = io.ReadAll(stdin)
+	} else {
+		sec, err = os.ReadFile(*in)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	envelopes, err := m.DistributeEnvelopesWithRand(nil, random, sec, anyone{}, macKey)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := m.ArmorShares(envelopes)
+	if err != nil {
+		return nil, err
+	}
+
+	out := splitOutput{Predicate: msp.Formatted(m).String(), Users: make(map[string]int)}
+	for name, userShares := range envelopes {
+		if filepath.Base(name) != name || name == "." || name == ".." {
+			return nil, fail(exitPredicate, errors.New("User names can't be used as file names: "+name))
+		}
+
+		out.Users[name] = len(userShares)
+
+		e, err := msp.UnmarshalEnvelope(userShares[0])
+		if err != nil {
+			return nil, err
+		}
+		out.SplitID = hex.EncodeToString(e.SplitID[:])
+	}
+
+	if err := os.MkdirAll(*outDir, 0700); err != nil {
+		return nil, err
+	}
+
+	// Every path is checked before anything is written, and the files already
+	// written are removed if a later one fails, so a split is never left
+	// half-written.
+	for name := range files {
+		path := filepath.Join(*outDir, name+".pem")
+		if _, err := os.Lstat(path); err == nil {
+			return nil, fmt.Errorf("%v already exists.", path)
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	written := []string{}
+	for name, file := range files {
+		path := filepath.Join(*outDir, name+".pem")
+		if err := writeNew(path, file); err != nil {
+			for _, path := range written {
+				os.Remove(path)
+			}
+			return nil, err
+		}
+		written = append(written, path)
+	}
+
+	return out, nil
+}
+
+// writeNew writes a file, refusing to overwrite an existing one.  If writing
+// fails, the file is removed.
+func writeNew(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+
+	return err
+}
@@ -0,0 +1,134 @@
+package msp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrMalformedShareFile is wrapped by the ShareFileError returned when a user's
+// share file can be read, but doesn't hold a valid set of their shares.
+var ErrMalformedShareFile = errors.New("Share file is malformed.")
+
+// A ShareFileError is returned by DirectoryDatabase.GetShare when a user's
+// share file is missing, can't be read, or is malformed.  Err wraps
+// fs.ErrNotExist, fs.ErrPermission or ErrMalformedShareFile, so callers can
+// tell which with errors.Is.
+type ShareFileError struct {
+	User string
+	Path string
+	Err  error
+}
+
+func (sfe *ShareFileError) Error() string {
+	return fmt.Sprintf("Share file of %v (%v): %v", sfe.User, sfe.Path, sfe.Err)
+}
+
+func (sfe *ShareFileError) Unwrap() error { return sfe.Err }
+
+// A DirectoryDatabase is a UserDatabase that reads shares from a directory with
+// one file per user, named after them with a .pem extension, holding their
+// armored share envelopes as written by MSP.ArmorShares.  Files are only read
+// by GetShare, so shares can be added to the directory as their holders show
+// up.  The users who may appear in a predicate are listed in a roster file.
+//
+// GetShare returns marshalled envelopes, so the secret is recovered with
+// RecoverSecretFromEnvelopes.
+type DirectoryDatabase struct {
+	dir    string
+	roster map[string]bool
+}
+
+// NewDirectoryDatabase returns a database of the share files in dir.  The
+// roster file has one user's name per line; blank lines and lines starting
+// with # are ignored.
+func NewDirectoryDatabase(dir, roster string) (*DirectoryDatabase, error) {
+	f, err := os.Open(roster)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dd := &DirectoryDatabase{dir: dir, roster: make(map[string]bool)}
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		name := strings.TrimSpace(scanner.Text())
+		if name == "" || name[0] == '#' {
+			continue
+		} else if filepath.Base(name) != name || name == "." || name == ".." {
+			return nil, fmt.Errorf("Roster line %v: %q can't be used as a file name.", line, name)
+		}
+
+		dd.roster[name] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return dd, nil
+}
+
+// ValidUser returns whether the user is in the roster.
+func (dd *DirectoryDatabase) ValidUser(name string) bool {
+	return dd.roster[name]
+}
+
+// CanGetShare returns whether the user is in the roster and has a share file.
+// The file isn't read.
+func (dd *DirectoryDatabase) CanGetShare(name string) bool {
+	if !dd.roster[name] {
+		return false
+	}
+
+	info, err := os.Stat(dd.path(name))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// GetShare reads and checks the user's share file, returning their marshalled
+// envelopes in order.
+func (dd *DirectoryDatabase) GetShare(name string) ([][]byte, error) {
+	if !dd.roster[name] {
+		return nil, errors.New("Unknown user.")
+	}
+
+	path := dd.path(name)
+	fail := func(err error) ([][]byte, error) {
+		return nil, &ShareFileError{name, path, err}
+	}
+	malformed := func(format string, a ...interface{}) ([][]byte, error) {
+		return fail(fmt.Errorf("%w "+format, append([]interface{}{ErrMalformedShareFile}, a...)...))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fail(err)
+	}
+
+	shares, err := Dearmor(data)
+	if err != nil {
+		return malformed("%v", err)
+	}
+
+	out := make([][]byte, len(shares))
+	for _, as := range shares {
+		if as.Holder != name {
+			return malformed("Share belongs to %q.", as.Holder)
+		} else if int(as.Envelope.Index) >= len(out) || out[as.Envelope.Index] != nil {
+			return malformed("Share index %v is out of place.", as.Envelope.Index)
+		}
+
+		if out[as.Envelope.Index], err = as.Envelope.Marshal(); err != nil {
+			return malformed("%v", err)
+		}
+	}
+
+	return out, nil
+}
+
+func (dd *DirectoryDatabase) path(name string) string {
+	return filepath.Join(dd.dir, name+".pem")
+}
@@ -0,0 +1,118 @@
+package msp
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeShareDirectory splits sec and writes the armored shares of the named
+// users to dir, along with a roster of every user in the predicate.
+func writeShareDirectory(t *testing.T, dir string, predicate MSP, sec []byte, names ...string) {
+	roster := "# Everybody.\nAlice\nBob\n\nCarl\n"
+	if err := os.WriteFile(filepath.Join(dir, "roster"), []byte(roster), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	db := &Database{"Alice": nil, "Bob": nil, "Carl": nil}
+	shares, err := predicate.DistributeEnvelopes(nil, sec, db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := predicate.ArmorShares(shares)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name+".pem"), files[name], 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDirectoryDatabase(t *testing.T) {
+	dir := t.TempDir()
+	sec := []byte("attack at dawn")
+	predicate, _ := StringToMSP("(2, (1, Alice, Bob), (2, Alice, Carl), Carl)")
+
+	writeShareDirectory(t, dir, predicate, sec, "Alice", "Carl")
+
+	db, err := NewDirectoryDatabase(dir, filepath.Join(dir, "roster"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !db.ValidUser("Bob") || db.ValidUser("Dave") || db.ValidUser("roster") {
+		t.Fatalf("Roster was read wrong.")
+	} else if !db.CanGetShare("Alice") || db.CanGetShare("Bob") || db.CanGetShare("Dave") {
+		t.Fatalf("Share files were found wrong.")
+	}
+
+	out, err := predicate.RecoverSecretFromEnvelopes(db, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(sec, out) {
+		t.Fatalf("Secrets derived differed:  %x %x", sec, out)
+	}
+
+	// Shares can be split to the roster too.
+	if _, err := predicate.DistributeShares(sec, db); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDirectoryDatabaseErrors(t *testing.T) {
+	dir := t.TempDir()
+	predicate, _ := StringToMSP("(2, (1, Alice, Bob), (2, Alice, Carl), Carl)")
+
+	writeShareDirectory(t, dir, predicate, []byte("attack at dawn"), "Alice", "Carl")
+
+	db, err := NewDirectoryDatabase(dir, filepath.Join(dir, "roster"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Bob's file is missing.
+	var sfe *ShareFileError
+	if _, err := db.GetShare("Bob"); !errors.As(err, &sfe) || sfe.User != "Bob" || !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Missing file wasn't reported: %v", err)
+	}
+
+	// Carl's file is Alice's shares.
+	alice, _ := os.ReadFile(filepath.Join(dir, "Alice.pem"))
+	os.WriteFile(filepath.Join(dir, "Carl.pem"), alice, 0600)
+	if _, err := db.GetShare("Carl"); !errors.Is(err, ErrMalformedShareFile) || !strings.Contains(err.Error(), "Alice") {
+		t.Fatalf("Wrong holder wasn't reported: %v", err)
+	}
+
+	// Alice's file is corrupt.
+	os.WriteFile(filepath.Join(dir, "Alice.pem"), alice[:len(alice)/4], 0600)
+	if _, err := db.GetShare("Alice"); !errors.Is(err, ErrMalformedShareFile) {
+		t.Fatalf("Corrupt file wasn't reported: %v", err)
+	}
+
+	// Bob's file is a directory.
+	os.Mkdir(filepath.Join(dir, "Bob.pem"), 0700)
+	if db.CanGetShare("Bob") {
+		t.Fatalf("Directory was taken for a share file!")
+	} else if _, err := db.GetShare("Bob"); !errors.As(err, &sfe) || errors.Is(err, ErrMalformedShareFile) {
+		t.Fatalf("Unreadable file wasn't reported: %v", err)
+	}
+
+	if _, err := db.GetShare("Dave"); err == nil {
+		t.Fatalf("Unknown user was accepted!")
+	}
+
+	os.WriteFile(filepath.Join(dir, "roster"), []byte("Alice\n../Bob\n"), 0600)
+	if _, err := NewDirectoryDatabase(dir, filepath.Join(dir, "roster")); err == nil {
+		t.Fatalf("Bad name in roster was accepted!")
+	} else if _, err := NewDirectoryDatabase(dir, filepath.Join(dir, "missing")); err == nil {
+		t.Fatalf("Missing roster was accepted!")
+	}
+}
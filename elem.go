@@ -1,5 +1,7 @@
 package msp
 
+import "crypto/subtle"
+
 type Elem struct {
 	Field
 
@@ -14,9 +16,7 @@ func (e Elem) Bytes() []byte {
 
 // AddM mutates e into e+f.
 func (e Elem) AddM(f Elem) {
-	for i := 0; i < e.Size(); i++ {
-		e.e[i] ^= f.e[i]
-	}
+	e.Field.add(e.e, e.e, f.e)
 }
 
 // Add returns e+f.
@@ -27,27 +27,23 @@ func (e Elem) Add(f Elem) Elem {
 	return elem
 }
 
-// Mul returns e*f.
-func (e Elem) Mul(f Elem) Elem {
-	elem := e.Zero()
-
-	for i := 0; i < e.BitSize(); i++ { // Foreach bit e_i in e:
-		if e.getCoeff(i) == 1 { // where e_i equals 1:
-			temp := f.Dup() // Multiply f * x^i mod M(x):
+// SubM mutates e into e-f.
+func (e Elem) SubM(f Elem) {
+	e.Field.sub(e.e, e.e, f.e)
+}
 
-			for j := 0; j < i; j++ { // Multiply f by x mod M(x), i times.
-				carry := temp.shift()
+// Sub returns e-f.
+func (e Elem) Sub(f Elem) Elem {
+	elem := e.Dup()
+	elem.SubM(f)
 
-				if carry {
-					for k := range e.Field {
-						temp.e[k] ^= e.Field[k]
-					}
-				}
-			}
+	return elem
+}
 
-			elem.AddM(temp) // Add f * x^i to the output
-		}
-	}
+// Mul returns e*f.
+func (e Elem) Mul(f Elem) Elem {
+	elem := e.Zero()
+	e.Field.mul(elem.e, e.e, f.e)
 
 	return elem
 }
@@ -63,42 +59,37 @@ func (e Elem) Exp(i int) Elem {
 	return elem
 }
 
-// Invert returns the multiplicative inverse of e.
+// Invert returns the multiplicative inverse of e.  The inverse of 0 is 0.
 func (e Elem) Invert() Elem {
-	elem, temp := e.Dup(), e.Dup()
-
-	rounds := e.BitSize() - 2
-	for i := 0; i < rounds; i++ {
-		temp = temp.Mul(temp)
-		elem = elem.Mul(temp)
-	}
+	elem := e.Zero()
+	e.Field.invert(elem.e, e.e)
 
-	return elem.Mul(elem)
+	return elem
 }
 
-// getCoeff returns the ith coefficient of the field element: either 0 or 1.
-func (e Elem) getCoeff(i int) byte {
-	return (e.e[i/8] >> (uint(i) % 8)) & 1
+// Dup returns a duplicate of e.
+func (e Elem) Dup() Elem {
+	return e.Field.Elem(e.e)
 }
 
-// shift multiplies e by 2 and returns true if there was overflow and false if there wasn't.
-func (e Elem) shift() bool {
-	carry := false
-
-	for i := 0; i < e.Size(); i++ {
-		nextCarry := (e.e[i] >= 128)
-
-		e.e[i] = (e.e[i] << 1)
-		if carry {
-			e.e[i]++
-		}
-		carry = nextCarry
+// isZero returns 1 if e is zero and 0 if it isn't, in constant time.
+func (e Elem) isZero() int {
+	acc := byte(0)
+	for _, b := range e.e {
+		acc |= b
 	}
 
-	return carry
+	return subtle.ConstantTimeByteEq(acc, 0)
 }
 
-// Dup returns a duplicate of e.
-func (e Elem) Dup() Elem {
-	return e.Field.Elem(e.e)
+// condSwap swaps the values of e and f if swap is 1 and leaves them alone if
+// swap is 0, in constant time.
+func (e Elem) condSwap(f Elem, swap int) {
+	mask := byte(-swap)
+
+	for i := range e.e {
+		t := mask & (e.e[i] ^ f.e[i])
+		e.e[i] ^= t
+		f.e[i] ^= t
+	}
 }
@@ -0,0 +1,253 @@
+package msp
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Share repair computes the share of one condition of a threshold gate from
+// the shares of Min of the gate's other conditions, without anybody but the
+// recipient learning it, and without the recipient learning anything else.
+// Enrollment uses it to give a new condition, appended to the gate, a share;
+// it also recovers a holder's lost share.
+//
+// The gate's shares are points on a polynomial f, so the target share f(x) is
+// a linear combination of the helpers' shares, with Lagrange coefficients
+// found by Matrix.Solve.  In the first round, each helper weights their share
+// by their coefficient, splits the result into random pieces that add up to
+// it, and sends one piece to each helper, from RepairPieces.  In the second,
+// each helper adds up the pieces they receive and sends the sum to the
+// recipient, who adds up the sums.  Both are done by SumRepairParts.  Every
+// sum the recipient sees is uniformly random, but together they add up to the
+// share.
+//
+// Helpers must be Name conditions of the gate itself, since nobody holds the
+// shares of nested gates.  Gates are found by their path from the top of the
+// MSP:  the condition indices of each nested gate along the way, so nil is the
+// top-level gate.
+
+// Enroll returns the MSP with a new leaf for the named user appended to the
+// gate at path, and the index of the new leaf among the user's shares.  Any of
+// the user's shares at or after that index move up by one.  The new leaf's
+// share is computed by share repair, with the target set to the number of
+// conditions the gate had before.
+//
+// The user can't already have a leaf in the gate, since a second one would let
+// them fill two of its conditions alone.  Their name must survive being parsed
+// back from the predicate's string:  it can't be empty, have spaces at either
+// end, or contain commas or parentheses.
+func (m MSP) Enroll(path []int, name string) (MSP, int, error) {
+	gate, err := m.gateAt(path)
+	if err != nil {
+		return m, 0, err
+	} else if name == "" || name != strings.TrimSpace(name) || strings.ContainsAny(name, ",()") {
+		return m, 0, errors.New("Name can't be written in a predicate.")
+	}
+
+	for _, cond := range gate.Conds {
+		if cond, ok := cond.(Name); ok && cond.string == name {
+			return m, 0, errors.New("User already has a leaf in the threshold gate.")
+		}
+	}
+
+	next := MSP(enroll(Formatted(m), path, name))
+
+	// Renumber every name's leaves in order, and find the new one.
+	counts, index := make(map[string]int), -1
+	next = MSP(renumber(Formatted(next), counts, path, true, &index))
+
+	return next, index, nil
+}
+
+// enroll returns a copy of f with a new leaf appended to the gate at path.
+func enroll(f Formatted, path []int, name string) Formatted {
+	out := Formatted{Min: f.Min, Conds: append([]Condition{}, f.Conds...)}
+
+	if len(path) == 0 {
+		out.Conds = append(out.Conds, Name{name, 0})
+	} else {
+		out.Conds[path[0]] = enroll(f.Conds[path[0]].(Formatted), path[1:], name)
+	}
+
+	return out
+}
+
+// renumber returns a copy of f with the index of each name's leaves counted up
+// from zero, in order.  If onPath is true, f is on the way to the gate at path,
+// and the index of that gate's last leaf is stored in newIndex.
+func renumber(f Formatted, counts map[string]int, path []int, onPath bool, newIndex *int) Formatted {
+	out := Formatted{Min: f.Min, Conds: make([]Condition, len(f.Conds))}
+
+	for i, cond := range f.Conds {
+		switch cond := cond.(type) {
+		case Name:
+			out.Conds[i] = Name{cond.string, counts[cond.string]}
+			counts[cond.string]++
+
+			if onPath && len(path) == 0 && i == len(f.Conds)-1 {
+				*newIndex = out.Conds[i].(Name).index
+			}
+
+		case Formatted:
+			if onPath && len(path) > 0 && path[0] == i {
+				out.Conds[i] = renumber(cond, counts, path[1:], true, newIndex)
+			} else {
+				out.Conds[i] = renumber(cond, counts, nil, false, newIndex)
+			}
+		}
+	}
+
+	return out
+}
+
+// gateAt returns the threshold gate at path.
+func (m MSP) gateAt(path []int) (MSP, error) {
+	for _, i := range path {
+		if i < 0 || i >= len(m.Conds) {
+			return m, errors.New("Path doesn't lead to a threshold gate.")
+		}
+
+		next, ok := m.Conds[i].(Formatted)
+		if !ok {
+			return m, errors.New("Path doesn't lead to a threshold gate.")
+		}
+		m = MSP(next)
+	}
+
+	return m, nil
+}
+
+// repairCoefficients returns the Lagrange coefficient of each helper's leaf,
+// by name and share index, for computing the share of condition target of the
+// gate at path.  The helpers are the first Min Name conditions of the gate
+// available in helpers, other than the target.
+func (m MSP) repairCoefficients(field Field, path []int, target int, helpers UserDatabase) (map[Name]Elem, error) {
+	gate, err := m.gateAt(path)
+	if err != nil {
+		return nil, err
+	} else if target < 0 || target > len(gate.Conds) {
+		return nil, errors.New("Target isn't a condition of the gate.")
+	} else if err := gate.validate(field.MaxPoints() - 1); err != nil {
+		return nil, err
+	}
+
+	locs := []int{}
+	for i, cond := range gate.Conds {
+		if name, ok := cond.(Name); ok && i != target && helpers.CanGetShare(name.string) && len(locs) < gate.Min {
+			locs = append(locs, i)
+		}
+	}
+	if len(locs) < gate.Min {
+		return nil, errors.New("Not enough helpers.")
+	}
+
+	// Find r such that the helpers' rows of the Vandermonde matrix, weighted by
+	// r, add up to the target's row.
+	r, ok := field.Vandermonde(locs, gate.Min).Solve(field.Vandermonde([]int{target}, gate.Min).m[0])
+	if !ok {
+		return nil, errors.New("Unable to find a reconstruction vector!")
+	}
+
+	coeffs := make(map[Name]Elem, len(locs))
+	for i, loc := range locs {
+		coeffs[gate.Conds[loc].(Name)] = r.r[i]
+	}
+
+	return coeffs, nil
+}
+
+// RepairPieces is the first round of share repair, run by each helper:  the
+// users available in helpers.  It returns the piece to send to each helper,
+// including the named user themselves.  target is the condition of the gate at
+// path whose share is being computed.  field is the field the secret was split
+// over, or nil if it was chosen by the secret's size.
+func (m MSP) RepairPieces(field Field, random io.Reader, path []int, target int, helpers UserDatabase, name string, shares [][]byte) (map[string][]byte, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("No shares to repair from.")
+	}
+
+	field, err := fieldOrDefault(field, len(shares[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	coeffs, err := m.repairCoefficients(field, path, target, helpers)
+	if err != nil {
+		return nil, err
+	}
+
+	// Weight each of the user's shares that are used by its coefficient.
+	size := len(shares[0])
+	weighted, found := make([]byte, size), false
+	names := []string{}
+
+	for leaf, c := range coeffs {
+		names = append(names, leaf.string)
+		if leaf.string != name {
+			continue
+		} else if leaf.index >= len(shares) || len(shares[leaf.index]) != size {
+			return nil, errors.New("Predicate / database mismatch!")
+		} else if err := checkElems(field, shares[leaf.index]); err != nil {
+			return nil, err
+		}
+
+		for k := 0; k < size; k += field.Size() {
+			sum := field.Elem(weighted[k : k+field.Size()]).Add(c.Mul(field.Elem(shares[leaf.index][k : k+field.Size()])))
+			copy(weighted[k:], sum.e)
+		}
+		found = true
+	}
+	if !found {
+		return nil, errors.New("User's shares aren't needed.")
+	}
+
+	sort.Strings(names)
+
+	// Split the weighted share into random pieces, one for each helper.  The
+	// user's own piece is whatever's left.
+	pieces := make(map[string][]byte)
+	for _, helper := range names {
+		if _, ok := pieces[helper]; ok || helper == name {
+			continue
+		}
+
+		piece := make([]byte, 0, size)
+		for len(piece) < size {
+			buf, err := field.random(random)
+			if err != nil {
+				return nil, err
+			}
+			piece = append(piece, buf...)
+		}
+		pieces[helper] = piece
+
+		for k := 0; k < size; k += field.Size() {
+			copy(weighted[k:], field.Elem(weighted[k:k+field.Size()]).Sub(field.Elem(piece[k:k+field.Size()])).e)
+		}
+	}
+	pieces[name] = weighted
+
+	return pieces, nil
+}
+
+// SumRepairParts adds up the pieces a helper receives in the first round of
+// share repair, giving the sum they send to the recipient, and also adds up
+// the sums the recipient receives, giving the repaired share.
+func SumRepairParts(field Field, parts [][]byte) ([]byte, error) {
+	if len(parts) == 0 {
+		return nil, errors.New("Nothing to add up.")
+	}
+
+	out := parts[0]
+	for _, part := range parts[1:] {
+		sum, err := addShares(field, out, part)
+		if err != nil {
+			return nil, err
+		}
+		out = sum
+	}
+
+	return append([]byte{}, out...), nil
+}
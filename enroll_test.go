@@ -0,0 +1,136 @@
+package msp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+// repair runs both rounds of share repair in-process, and returns the share
+// the recipient computes.
+func repair(t *testing.T, m MSP, field Field, path []int, target int, helpers Database) []byte {
+	received := make(map[string][][]byte)
+	for name, shares := range helpers {
+		pieces, err := m.RepairPieces(field, rand.Reader, path, target, &helpers, name, shares)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for helper, piece := range pieces {
+			received[helper] = append(received[helper], piece)
+		}
+	}
+
+	sums := [][]byte{}
+	for helper, pieces := range received {
+		if _, ok := helpers[helper]; !ok {
+			t.Fatalf("Piece was sent to %v, who isn't a helper.", helper)
+		}
+
+		sum, err := SumRepairParts(field, pieces)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sums = append(sums, sum)
+	}
+
+	share, err := SumRepairParts(field, sums)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return share
+}
+
+func TestEnroll(t *testing.T) {
+	db := &Database{"Alice": nil, "Bob": nil, "Carl": nil, "Dave": nil}
+	predicate, _ := StringToMSP("(2, Alice, Bob, Carl)")
+
+	for _, size := range []int{7, 16, 32} {
+		sec := make([]byte, size)
+		rand.Read(sec)
+
+		shares, err := predicate.DistributeShares(sec, db)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		next, index, err := predicate.Enroll(nil, "Dave")
+		if err != nil {
+			t.Fatal(err)
+		} else if s := Formatted(next).String(); s != "(2, Alice, Bob, Carl, Dave)" || index != 0 {
+			t.Fatalf("Predicate was enrolled wrong: %v %v", s, index)
+		}
+
+		dave := repair(t, predicate, nil, nil, 3, Database{"Alice": shares["Alice"], "Bob": shares["Bob"]})
+
+		subset := Database{"Carl": shares["Carl"], "Dave": [][]byte{dave}}
+		out, err := next.RecoverSecret(&subset)
+		if err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(sec, out) {
+			t.Fatalf("Size %v: Secrets derived differed:  %x %x", size, sec, out)
+		}
+
+		// Carl's lost share is repaired the same way.
+		carl := repair(t, next, nil, nil, 2, Database{"Bob": shares["Bob"], "Dave": [][]byte{dave}})
+		if !bytes.Equal(carl, shares["Carl"][0]) {
+			t.Fatalf("Size %v: Repaired share was wrong:  %x %x", size, carl, shares["Carl"][0])
+		}
+	}
+}
+
+func TestEnrollNested(t *testing.T) {
+	db := &Database{"Alice": nil, "Bob": nil, "Carl": nil, "Dave": nil}
+	predicate, _ := StringToMSP("(2, (2, Alice, Bob, Carl), Dave)")
+	sec := make([]byte, 16)
+	rand.Read(sec)
+
+	shares, err := predicate.DistributeShares(sec, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Dave gets a leaf in the nested gate, before his leaf at the top.
+	next, index, err := predicate.Enroll([]int{0}, "Dave")
+	if err != nil {
+		t.Fatal(err)
+	} else if s := Formatted(next).String(); s != "(2, (2, Alice, Bob, Carl, Dave), Dave)" || index != 0 {
+		t.Fatalf("Predicate was enrolled wrong: %v %v", s, index)
+	}
+
+	share := repair(t, predicate, nil, []int{0}, 3, Database{"Alice": shares["Alice"], "Carl": shares["Carl"]})
+	dave := [][]byte{share, shares["Dave"][0]}
+
+	// Dave's new leaf and Alice satisfy the nested gate, but Dave alone doesn't
+	// satisfy anything.
+	for _, subset := range []Database{{"Alice": shares["Alice"], "Dave": dave}, {"Bob": shares["Bob"], "Dave": dave}} {
+		out, err := next.RecoverSecret(&subset)
+		if err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(sec, out) {
+			t.Fatalf("Secrets derived differed:  %x %x", sec, out)
+		}
+	}
+
+	alone := Database{"Dave": dave}
+	if _, err := next.RecoverSecret(&alone); err == nil {
+		t.Fatalf("Dave recovered the secret alone!")
+	}
+
+	// Nobody gets two leaves in one gate, and names have to be writable.
+	if _, _, err := predicate.Enroll([]int{0}, "Bob"); err == nil {
+		t.Fatalf("Enrolled Bob into a gate he's already in!")
+	}
+	for _, name := range []string{"", " Eve", "Eve, Fred", "(Eve", "Eve)"} {
+		if _, _, err := predicate.Enroll(nil, name); err == nil {
+			t.Fatalf("Enrolled unwritable name %q!", name)
+		}
+	}
+
+	if _, _, err := predicate.Enroll([]int{1}, "Eve"); err == nil {
+		t.Fatalf("Enrolled into a leaf!")
+	} else if _, err := predicate.RepairPieces(nil, rand.Reader, []int{0}, 3, &Database{"Alice": nil}, "Alice", shares["Alice"]); err == nil {
+		t.Fatalf("Repaired with too few helpers!")
+	}
+}
@@ -0,0 +1,300 @@
+package msp
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// EnvelopeVersion is the version of the share envelope format written by
+// Marshal.
+const EnvelopeVersion = 1
+
+// ErrWrongSplit is returned when shares from different splits, or for a
+// different predicate, are combined.
+var ErrWrongSplit = errors.New("Share belongs to a different split.")
+
+// FieldID identifies the field a share is an element of.
+type FieldID byte
+
+const (
+	FieldGF256      FieldID = 1 // Fields[1], including byte-wise splits of any length.
+	FieldGF2_128    FieldID = 2 // Fields[16]
+	FieldGF2_256    FieldID = 3 // Fields[32]
+	FieldP256       FieldID = 4 // P256Field
+	FieldCurve25519 FieldID = 5 // Curve25519Field
+)
+
+var fieldIDs = map[FieldID]Field{
+	FieldGF256:      Fields[1],
+	FieldGF2_128:    Fields[16],
+	FieldGF2_256:    Fields[32],
+	FieldP256:       P256Field,
+	FieldCurve25519: Curve25519Field,
+}
+
+// FieldByID returns the field with the given ID.
+func FieldByID(id FieldID) (Field, bool) {
+	field, ok := fieldIDs[id]
+	return field, ok
+}
+
+// IDOfField returns the ID of a built-in field.
+func IDOfField(field Field) (FieldID, bool) {
+	for id, other := range fieldIDs {
+		if sameField(field, other) {
+			return id, true
+		}
+	}
+
+	return 0, false
+}
+
+func sameField(a, b Field) bool {
+	switch a := a.(type) {
+	case BinaryField:
+		b, ok := b.(BinaryField)
+		return ok && bytes.Equal(a, b)
+	case PrimeField:
+		b, ok := b.(PrimeField)
+		return ok && a.p.Cmp(b.p) == 0
+	}
+
+	return false
+}
+
+// An Envelope wraps one share with enough metadata to tell which split,
+// predicate and leaf it belongs to.
+type Envelope struct {
+	Version   byte
+	Field     FieldID
+	SplitID   [16]byte // Random, and the same for every share of one split.
+	Predicate [32]byte // Hash of the predicate, from MSP.Hash.
+	Index     uint32   // Position of the share in its holder's shares.
+	Share     []byte
+	MAC       []byte // Optional HMAC-SHA256 of the rest of the envelope.
+}
+
+// Hash returns the SHA-256 hash of the MSP's formatted predicate, which
+// identifies it in share envelopes.
+func (m MSP) Hash() [32]byte {
+	return sha256.Sum256([]byte(Formatted(m).String()))
+}
+
+// Marshal encodes the envelope as:
+//
+//	version (1) || field (1) || split ID (16) || predicate (32) ||
+//	index (4) || share length (4) || share || MAC length (1) || MAC
+//
+// with integers big-endian.  It fails if the share or MAC is too long for its
+// length field.
+func (e Envelope) Marshal() ([]byte, error) {
+	if uint64(len(e.Share)) > math.MaxUint32 {
+		return nil, errors.New("Envelope's share is too long.")
+	} else if len(e.MAC) > math.MaxUint8 {
+		return nil, errors.New("Envelope's MAC is too long.")
+	}
+
+	out := make([]byte, 0, 59+len(e.Share)+len(e.MAC))
+	out = append(out, e.Version, byte(e.Field))
+	out = append(out, e.SplitID[:]...)
+	out = append(out, e.Predicate[:]...)
+	out = binary.BigEndian.AppendUint32(out, e.Index)
+	out = binary.BigEndian.AppendUint32(out, uint32(len(e.Share)))
+	out = append(out, e.Share...)
+	out = append(out, byte(len(e.MAC)))
+	out = append(out, e.MAC...)
+
+	return out, nil
+}
+
+// UnmarshalEnvelope decodes an envelope encoded by Marshal.
+func UnmarshalEnvelope(b []byte) (e Envelope, err error) {
+	if len(b) < 58 {
+		return e, errors.New("Envelope is too short.")
+	}
+
+	e.Version, e.Field = b[0], FieldID(b[1])
+	if e.Version != EnvelopeVersion {
+		return e, errors.New("Unknown envelope version.")
+	}
+
+	copy(e.SplitID[:], b[2:18])
+	copy(e.Predicate[:], b[18:50])
+	e.Index = binary.BigEndian.Uint32(b[50:54])
+
+	shareLen := binary.BigEndian.Uint32(b[54:58])
+	b = b[58:]
+	if uint64(len(b)) < uint64(shareLen)+1 {
+		return e, errors.New("Envelope is too short.")
+	}
+	e.Share, b = append([]byte{}, b[:shareLen]...), b[shareLen:]
+
+	macLen := int(b[0])
+	if len(b) != 1+macLen {
+		return e, errors.New("Envelope is the wrong length.")
+	}
+	if macLen > 0 {
+		e.MAC = append([]byte{}, b[1:]...)
+	}
+
+	return e, nil
+}
+
+// Sign sets the envelope's MAC using the given key.
+func (e *Envelope) Sign(key []byte) {
+	e.MAC = e.computeMAC(key)
+}
+
+// Verify checks the envelope's MAC using the given key.
+func (e Envelope) Verify(key []byte) bool {
+	return len(e.MAC) > 0 && hmac.Equal(e.MAC, e.computeMAC(key))
+}
+
+func (e Envelope) computeMAC(key []byte) []byte {
+	e.MAC = nil
+
+	// The MAC is cleared, so Marshal can only fail on a share too long to sign.
+	b, _ := e.Marshal()
+
+	h := hmac.New(sha256.New, key)
+	h.Write(b)
+
+	return h.Sum(nil)
+}
+
+// DistributeEnvelopes splits a secret like DistributeSharesInField, but wraps
+// each share in a marshalled Envelope.  If field is nil, one is chosen by the
+// secret's length as in DistributeShares.  If macKey isn't nil, every envelope
+// is signed with it.
+func (m MSP) DistributeEnvelopes(field Field, sec []byte, db UserDatabase, macKey []byte) (map[string][][]byte, error) {
+	return m.DistributeEnvelopesWithRand(field, rand.Reader, sec, db, macKey)
+}
+
+// DistributeEnvelopesWithRand is the same as DistributeEnvelopes, but reads the
+// split ID and the randomness used to generate shares from the given source.
+func (m MSP) DistributeEnvelopesWithRand(field Field, random io.Reader, sec []byte, db UserDatabase, macKey []byte) (map[string][][]byte, error) {
+	field, err := fieldOrDefault(field, len(sec))
+	if err != nil {
+		return nil, err
+	}
+
+	id, ok := IDOfField(field)
+	if !ok {
+		return nil, errors.New("Field has no ID.")
+	}
+
+	e := Envelope{Version: EnvelopeVersion, Field: id, Predicate: m.Hash()}
+	if _, err := io.ReadFull(random, e.SplitID[:]); err != nil {
+		return nil, err
+	}
+
+	shares, err := m.DistributeSharesInField(field, random, sec, db)
+	if err != nil {
+		return nil, err
+	}
+
+	return sealEnvelopes(e, shares, macKey)
+}
+
+// sealEnvelopes wraps each of the shares in a copy of e, with its index set,
+// and signs it with macKey if it isn't nil.  The shares map is reused.
+func sealEnvelopes(e Envelope, shares map[string][][]byte, macKey []byte) (map[string][][]byte, error) {
+	for name, userShares := range shares {
+		for i, share := range userShares {
+			e.Index, e.Share = uint32(i), share
+			if macKey != nil {
+				e.Sign(macKey)
+			}
+
+			b, err := e.Marshal()
+			if err != nil {
+				return nil, err
+			}
+			userShares[i] = b
+		}
+		shares[name] = userShares
+	}
+
+	return shares, nil
+}
+
+// RecoverSecretFromEnvelopes recovers a secret from a user database storing
+// marshalled envelopes, as returned by DistributeEnvelopes.  Every envelope
+// must be for this predicate and from the same split, or ErrWrongSplit is
+// returned.  If macKey isn't nil, every envelope's MAC is checked with it.
+func (m MSP) RecoverSecretFromEnvelopes(db UserDatabase, macKey []byte) ([]byte, error) {
+	edb := &envelopeDatabase{UserDatabase: db, predicate: m.Hash(), macKey: macKey}
+	cache := make(map[string][][]byte, 0)
+
+	return m.recoverSecret(edb, cache, envelopeCombiner{edb})
+}
+
+// envelopeDatabase unwraps the envelopes stored in a user database, checking
+// that they all belong together.
+type envelopeDatabase struct {
+	UserDatabase
+
+	predicate [32]byte
+	macKey    []byte
+
+	seen    bool // Whether the fields below have been set by a share yet.
+	splitID [16]byte
+	field   FieldID
+}
+
+func (edb *envelopeDatabase) GetShare(name string) ([][]byte, error) {
+	raw, err := edb.UserDatabase.GetShare(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return edb.open(raw)
+}
+
+// open unwraps one user's envelopes.
+func (edb *envelopeDatabase) open(raw [][]byte) ([][]byte, error) {
+	out := make([][]byte, len(raw))
+	for i, b := range raw {
+		e, err := UnmarshalEnvelope(b)
+		if err != nil {
+			return nil, err
+		} else if edb.macKey != nil && !e.Verify(edb.macKey) {
+			return nil, errors.New("Envelope's MAC is invalid.")
+		} else if e.Predicate != edb.predicate || e.Index != uint32(i) {
+			return nil, ErrWrongSplit
+		}
+
+		if !edb.seen {
+			edb.seen, edb.splitID, edb.field = true, e.SplitID, e.Field
+		} else if e.SplitID != edb.splitID || e.Field != edb.field {
+			return nil, ErrWrongSplit
+		}
+
+		out[i] = e.Share
+	}
+
+	return out, nil
+}
+
+// envelopeCombiner recovers secrets over the field named by the envelopes read
+// from its database.
+type envelopeCombiner struct {
+	edb *envelopeDatabase
+}
+
+func (ec envelopeCombiner) Combine(m MSP, locs []int, shares [][]byte) ([]byte, error) {
+	field, ok := FieldByID(ec.edb.field)
+	if !ok {
+		return nil, errors.New("Unknown field in envelope.")
+	}
+
+	return fieldCombiner{field}.Combine(m, locs, shares)
+}
+
+func (ec envelopeCombiner) Child(i int) combiner { return ec }
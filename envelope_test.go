@@ -0,0 +1,112 @@
+package msp
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestEnvelopeMarshal(t *testing.T) {
+	e := Envelope{
+		Version:   EnvelopeVersion,
+		Field:     FieldGF2_128,
+		SplitID:   [16]byte{1, 2, 3},
+		Predicate: [32]byte{4, 5, 6},
+		Index:     3,
+		Share:     []byte("0123456789abcdef"),
+	}
+
+	b, err := e.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := UnmarshalEnvelope(b)
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(e, out) {
+		t.Fatalf("Envelope changed after marshalling:\n%+v\n%+v", e, out)
+	}
+
+	e.Sign([]byte("key"))
+	if b, err = e.Marshal(); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err = UnmarshalEnvelope(b)
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(e, out) {
+		t.Fatalf("Envelope changed after marshalling:\n%+v\n%+v", e, out)
+	} else if !out.Verify([]byte("key")) || out.Verify([]byte("other key")) {
+		t.Fatalf("MAC verification is wrong!")
+	}
+
+	out.Index = 4
+	if out.Verify([]byte("key")) {
+		t.Fatalf("Modified envelope verified!")
+	}
+
+	for _, bad := range [][]byte{b[:20], b[:len(b)-1], append(b, 0), append([]byte{2}, b[1:]...)} {
+		if _, err := UnmarshalEnvelope(bad); err == nil {
+			t.Fatalf("Malformed envelope was accepted: %x", bad)
+		}
+	}
+
+	// The MAC's length has to fit in a byte.
+	e.MAC = make([]byte, 256)
+	if _, err := e.Marshal(); err == nil {
+		t.Fatalf("Envelope with a 256-byte MAC was marshalled!")
+	}
+}
+
+func TestEnvelopes(t *testing.T) {
+	db := &Database{
+		"Alice": [][]byte{},
+		"Bob":   [][]byte{},
+		"Carl":  [][]byte{},
+	}
+
+	predicate, _ := StringToMSP("(2, (1, Alice, Bob), (2, Alice, Carl), Carl)")
+	key := []byte("mac key")
+
+	for _, field := range []Field{nil, Fields[16], P256Field} {
+		sec := make([]byte, 32)
+		sec[31] = 42
+
+		shares, err := predicate.DistributeEnvelopes(field, sec, db, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sharesDb := Database(shares)
+		out, err := predicate.RecoverSecretFromEnvelopes(&sharesDb, key)
+		if err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(sec, out) {
+			t.Fatalf("Secrets derived differed:  %x %x", sec, out)
+		}
+
+		// Shares from another split are rejected.
+		other, err := predicate.DistributeEnvelopes(field, sec, db, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mixed := Database{"Alice": shares["Alice"], "Carl": other["Carl"]}
+		if _, err := predicate.RecoverSecretFromEnvelopes(&mixed, key); err != ErrWrongSplit {
+			t.Fatalf("Shares from different splits weren't rejected: %v", err)
+		}
+
+		// So are shares for a different predicate.
+		otherPredicate, _ := StringToMSP("(2, (1, Alice, Bob), (2, Alice, Carl), Bob)")
+		if _, err := otherPredicate.RecoverSecretFromEnvelopes(&sharesDb, key); err != ErrWrongSplit {
+			t.Fatalf("Shares for a different predicate weren't rejected: %v", err)
+		}
+
+		// And shares with a bad MAC.
+		if _, err := predicate.RecoverSecretFromEnvelopes(&sharesDb, []byte("wrong key")); err == nil {
+			t.Fatalf("Shares with a bad MAC weren't rejected!")
+		}
+	}
+}
@@ -1,30 +1,54 @@
 package msp
 
-type Field []byte
+import "io"
+
+// A Field is a finite field that secrets can be split over.  Elements are
+// stored as fixed-length byte strings, and Elem, Row and Matrix do arithmetic
+// on them through the Field they belong to.
+//
+// BinaryField implements GF(2^n) and PrimeField implements GF(p).
+type Field interface {
+	Elem(val []byte) Elem
+	Row(width int) Row
+	Matrix(height, width int) Matrix
+	Zero() Elem
+	One() Elem
+
+	// Size returns the length of an encoded element in bytes.
+	Size() int
+
+	// Point returns the element of the field that a threshold gate's ith
+	// condition is evaluated at, which is i+1.
+	Point(i int) Elem
+
+	// MaxPoints returns the number of distinct non-zero evaluation points in the
+	// field, which is the largest number of conditions a threshold gate over the
+	// field can have.
+	MaxPoints() int
+
+	// Vandermonde returns the matrix whose ith row is [1 x x^2 ... x^(width-1)],
+	// where x is the evaluation point of the condition points[i].
+	Vandermonde(points []int, width int) Matrix
+
+	// Arithmetic on encoded elements.  dst may alias the inputs.
+	add(dst, a, b []byte)
+	sub(dst, a, b []byte)
+	mul(dst, a, b []byte)
+	invert(dst, a []byte)
+
+	// random returns a uniformly random encoded element, read from r.
+	random(r io.Reader) ([]byte, error)
+}
 
 var Fields = map[int]Field{
+	1:  BuildField(1, 27),     // x^8 + x^4 + x^3 + x + 1
 	16: BuildField(16, 135),   // x^128 + x^7 + x^2 + x + 1
 	32: BuildField(32, 37, 4), // x^256 + x^10 + x^5 + x^2 + 1
 }
 
-func BuildField(size int, modulus ...byte) []byte {
-	field := make([]byte, size)
-	copy(field, modulus)
-
-	return field
-}
+// The following are shared by the implementations of Field.
 
-func (f Field) Elem(val []byte) Elem {
-	elem := Elem{
-		Field: f,
-		e:     make([]byte, len(f)),
-	}
-	copy(elem.e, val)
-
-	return elem
-}
-
-func (f Field) Row(width int) Row {
+func newRow(f Field, width int) Row {
 	row := Row{
 		Field: f,
 		r:     make([]Elem, width),
@@ -37,7 +61,7 @@ func (f Field) Row(width int) Row {
 	return row
 }
 
-func (f Field) Matrix(height, width int) Matrix {
+func newMatrix(f Field, height, width int) Matrix {
 	matrix := Matrix{
 		Field: f,
 		m:     make([]Row, height),
@@ -50,18 +74,16 @@ func (f Field) Matrix(height, width int) Matrix {
 	return matrix
 }
 
-func (f Field) Zero() Elem {
-	return f.Elem(nil)
-}
+func newVandermonde(f Field, points []int, width int) Matrix {
+	matrix := f.Matrix(len(points), width)
 
-func (f Field) One() Elem {
-	return f.Elem([]byte{1})
-}
+	for i, point := range points {
+		x := f.Point(point)
 
-func (f Field) Size() int {
-	return len(f)
-}
+		for j := range matrix.m[i].r {
+			matrix.m[i].r[j] = x.Exp(j)
+		}
+	}
 
-func (f Field) BitSize() int {
-	return f.Size() * 8
+	return matrix
 }
@@ -3,6 +3,7 @@ package msp
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -197,3 +198,144 @@ func (f *Formatted) Compress() {
 		}
 	}
 }
+
+// Users returns the number of shares each user in the predicate gets.
+func (f Formatted) Users() map[string]int {
+	out := make(map[string]int)
+	f.users(out)
+
+	return out
+}
+
+func (f Formatted) users(out map[string]int) {
+	for _, cond := range f.Conds {
+		switch cond := cond.(type) {
+		case Name:
+			out[cond.string]++
+		case Formatted:
+			cond.users(out)
+		}
+	}
+}
+
+// MinimalSets returns the minimal sets of users that satisfy the predicate:
+// the sets that do, but wouldn't without any one of their users.  Each set is
+// sorted, and the sets are sorted by size and then by name.  There can be
+// exponentially many, so false is returned if more than limit candidate sets
+// come up along the way.
+func (f Formatted) MinimalSets(limit int) ([][]string, bool) {
+	sets, ok := f.minimalSets(limit)
+	if !ok {
+		return nil, false
+	}
+
+	sort.Slice(sets, func(i, j int) bool {
+		if len(sets[i]) != len(sets[j]) {
+			return len(sets[i]) < len(sets[j])
+		}
+
+		for k := range sets[i] {
+			if sets[i][k] != sets[j][k] {
+				return sets[i][k] < sets[j][k]
+			}
+		}
+
+		return false
+	})
+
+	return sets, true
+}
+
+func (f Formatted) minimalSets(limit int) ([][]string, bool) {
+	children := make([][][]string, len(f.Conds))
+	for i, cond := range f.Conds {
+		switch cond := cond.(type) {
+		case Name:
+			children[i] = [][]string{{cond.string}}
+		case Formatted:
+			sets, ok := cond.minimalSets(limit)
+			if !ok {
+				return nil, false
+			}
+			children[i] = sets
+		}
+	}
+
+	// For every choice of Min conditions, take the union of one minimal set
+	// from each.
+	candidates := [][]string{}
+
+	var walk func(next, need int, acc []string) bool
+	walk = func(next, need int, acc []string) bool {
+		if need == 0 {
+			candidates = append(candidates, acc)
+			return len(candidates) <= limit
+		} else if len(f.Conds)-next < need {
+			return true
+		}
+
+		for _, set := range children[next] {
+			if !walk(next+1, need-1, unionSorted(acc, set)) {
+				return false
+			}
+		}
+
+		return walk(next+1, need, acc)
+	}
+
+	if !walk(0, f.Min, nil) {
+		return nil, false
+	}
+
+	// Drop every candidate that contains a smaller (or equal, earlier) one.
+	sort.SliceStable(candidates, func(i, j int) bool { return len(candidates[i]) < len(candidates[j]) })
+
+	out := [][]string{}
+	for _, cand := range candidates {
+		minimal := true
+		for _, set := range out {
+			if subsetSorted(set, cand) {
+				minimal = false
+				break
+			}
+		}
+
+		if minimal {
+			out = append(out, cand)
+		}
+	}
+
+	return out, true
+}
+
+// unionSorted returns the union of two sorted sets of names.
+func unionSorted(a, b []string) []string {
+	out := make([]string, 0, len(a)+len(b))
+
+	for len(a) > 0 && len(b) > 0 {
+		switch {
+		case a[0] < b[0]:
+			out, a = append(out, a[0]), a[1:]
+		case a[0] > b[0]:
+			out, b = append(out, b[0]), b[1:]
+		default:
+			out, a, b = append(out, a[0]), a[1:], b[1:]
+		}
+	}
+
+	return append(append(out, a...), b...)
+}
+
+// subsetSorted returns whether the sorted set a is a subset of the sorted set b.
+func subsetSorted(a, b []string) bool {
+	for len(a) > 0 && len(b) > 0 {
+		if a[0] == b[0] {
+			a = a[1:]
+		} else if a[0] < b[0] {
+			return false
+		}
+		b = b[1:]
+	}
+
+	return len(a) == 0
+}
@@ -1,6 +1,7 @@
 package msp
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -101,3 +102,28 @@ func TestBugs(t *testing.T) {
 		}
 	}
 }
+
+func TestFormattedUsers(t *testing.T) {
+	query, _ := StringToFormatted("(2, (1, Alice, Bob), (2, Alice, Carl), Carl)")
+
+	if users := query.Users(); !reflect.DeepEqual(users, map[string]int{"Alice": 2, "Bob": 1, "Carl": 2}) {
+		t.Fatalf("Users were wrong: %v", users)
+	}
+
+	sets, ok := query.MinimalSets(100)
+	if !ok {
+		t.Fatalf("Too many minimal sets?")
+	}
+
+	want := [][]string{{"Alice", "Carl"}, {"Bob", "Carl"}}
+	if !reflect.DeepEqual(sets, want) {
+		t.Fatalf("Minimal sets were wrong: %v", sets)
+	}
+
+	query, _ = StringToFormatted("(3, Alice, Bob, Carl, Dave, Eve)")
+	if sets, ok := query.MinimalSets(100); !ok || len(sets) != 10 || !reflect.DeepEqual(sets[0], []string{"Alice", "Bob", "Carl"}) {
+		t.Fatalf("Minimal sets were wrong: %v", sets)
+	} else if _, ok := query.MinimalSets(9); ok {
+		t.Fatalf("Limit on minimal sets was ignored!")
+	}
+}
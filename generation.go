@@ -0,0 +1,259 @@
+package msp
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Dealer-free secret generation.  Every dealer picks a random contribution and
+// splits it under the same MSP, sending each user their shares privately and,
+// for verifiable generation, broadcasting Feldman commitments.  Each user adds
+// up the shares they receive.  The joint secret is the sum of every
+// contribution, so nobody knows it unless every dealer colludes, but any
+// authorized set of users can recover it as usual.
+//
+// With commitments, each user checks every deal against its dealer's
+// commitments before accepting it, and the commitments to the joint secret are
+// the sums of the dealers' commitments.  Their constant term is g^secret, the
+// public key of the joint secret.
+
+// A Deal is the shares one dealer sends privately to one user.
+type Deal struct {
+	Dealer string
+	Shares [][]byte
+}
+
+// DealShares is run by each dealer.  It picks a random contribution of the
+// given size and splits it over field, or the default field for the size if
+// field is nil, returning the deal to send to each user in db.
+func (m MSP) DealShares(field Field, random io.Reader, dealer string, size int, db UserDatabase) (map[string]Deal, error) {
+	field, err := fieldOrDefault(field, size)
+	if err != nil {
+		return nil, err
+	}
+
+	sec := make([]byte, 0, size)
+	for len(sec) < size {
+		buf, err := field.random(random)
+		if err != nil {
+			return nil, err
+		}
+		sec = append(sec, buf...)
+	}
+
+	shares, err := m.DistributeSharesInField(field, random, sec, db)
+	if err != nil {
+		return nil, err
+	}
+
+	return deals(dealer, shares), nil
+}
+
+// DealSharesVerifiable is the same as DealShares, but the contribution is an
+// element of P256Field, and Feldman commitments to it are returned too.  The
+// commitments are broadcast to every user.
+func (m MSP) DealSharesVerifiable(random io.Reader, dealer string, db UserDatabase) (map[string]Deal, *Commitments, error) {
+	sec, err := P256Field.random(random)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := &Commitments{}
+
+	shares, err := m.distribute(sec, db, vssSplitter{random, c})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return deals(dealer, shares), c, nil
+}
+
+func deals(dealer string, shares map[string][][]byte) map[string]Deal {
+	out := make(map[string]Deal, len(shares))
+	for name, userShares := range shares {
+		out[name] = Deal{dealer, userShares}
+	}
+
+	return out
+}
+
+// CombineDeals is run by each user once they've received a deal from every
+// dealer.  It returns the user's shares of the joint secret.
+func (m MSP) CombineDeals(field Field, name string, deals []Deal) ([][]byte, error) {
+	if len(deals) == 0 {
+		return nil, errors.New("No deals to combine.")
+	}
+
+	seen := make(map[string]bool)
+
+	var out [][]byte
+	for i, deal := range deals {
+		if seen[deal.Dealer] {
+			return nil, fmt.Errorf("Deal from %v was given twice.", deal.Dealer)
+		}
+		seen[deal.Dealer] = true
+
+		if i == 0 {
+			if _, err := m.checkShares(field, map[string][][]byte{name: deal.Shares}); err != nil {
+				return nil, err
+			}
+			out = deal.Shares
+			continue
+		}
+
+		sum, err := m.AddShares(field, map[string][][]byte{name: out}, map[string][][]byte{name: deal.Shares})
+		if err != nil {
+			return nil, err
+		}
+		out = sum[name]
+	}
+
+	return out, nil
+}
+
+// CombineDealsVerifiable is the same as CombineDeals, but first checks each deal
+// against the commitments broadcast by its dealer.  If a deal is wrong, the
+// returned error wraps ErrInvalidShare and names the dealer.  It also returns
+// the commitments to the joint secret, which can be used with VerifyShare and
+// RecoverSecretVerifiable.
+func (m MSP) CombineDealsVerifiable(name string, deals []Deal, commitments map[string]*Commitments) ([][]byte, *Commitments, error) {
+	if len(deals) != len(commitments) {
+		return nil, nil, errors.New("Every dealer needs a deal and commitments.")
+	}
+
+	cs := make([]*Commitments, 0, len(deals))
+	for _, deal := range deals {
+		c, ok := commitments[deal.Dealer]
+		if !ok || c == nil {
+			return nil, nil, fmt.Errorf("No commitments from %v.", deal.Dealer)
+		} else if err := c.check(m); err != nil {
+			return nil, nil, fmt.Errorf("Commitments from %v: %w", deal.Dealer, err)
+		} else if c.Hiding {
+			return nil, nil, errors.New("Pedersen commitments aren't supported.")
+		}
+
+		for i, share := range deal.Shares {
+			if err := m.VerifyShare(name, i, share, c); err != nil {
+				return nil, nil, fmt.Errorf("Deal from %v: %w", deal.Dealer, err)
+			}
+		}
+		cs = append(cs, c)
+	}
+
+	out, err := m.CombineDeals(P256Field, name, deals)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	joint, err := CombineCommitments(cs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return out, joint, nil
+}
+
+// CombineCommitments returns the commitments to the sum of the secrets that cs
+// are commitments to, by adding them point-wise.  They must all be the same
+// kind, for the same predicate.
+func CombineCommitments(cs []*Commitments) (*Commitments, error) {
+	if len(cs) == 0 || cs[0] == nil {
+		return nil, errors.New("No commitments to combine.")
+	}
+
+	out := &Commitments{Hiding: cs[0].Hiding, Coeffs: make([][]byte, len(cs[0].Coeffs)), Conds: make([]*Commitments, len(cs[0].Conds))}
+
+	for _, c := range cs {
+		if c == nil || c.Hiding != out.Hiding || len(c.Coeffs) != len(out.Coeffs) || len(c.Conds) != len(out.Conds) {
+			return nil, errors.New("Commitments don't match.")
+		}
+	}
+
+	for j := range out.Coeffs {
+		x, y, err := decodePoint(cs[0].Coeffs[j])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range cs[1:] {
+			cx, cy, err := decodePoint(c.Coeffs[j])
+			if err != nil {
+				return nil, err
+			}
+			x, y = vssCurve.Add(x, y, cx, cy)
+		}
+
+		out.Coeffs[j] = encodePoint(x, y)
+	}
+
+	for i := range out.Conds {
+		if cs[0].Conds[i] == nil {
+			continue
+		}
+
+		conds := make([]*Commitments, len(cs))
+		for k, c := range cs {
+			conds[k] = c.Conds[i]
+		}
+
+		var err error
+		if out.Conds[i], err = CombineCommitments(conds); err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+// SimulateGeneration runs dealer-free generation in-process, with each of the
+// dealers dealing a contribution to the users in db, and returns every user's
+// shares of the joint secret.  If verifiable is true, the secret is an element of P256Field and the
+// joint commitments are returned too; otherwise size and field are used as in
+// DealShares.
+func (m MSP) SimulateGeneration(field Field, size int, verifiable bool, dealers []string, db UserDatabase) (map[string][][]byte, *Commitments, error) {
+	inbox := make(map[string][]Deal)
+	commitments := make(map[string]*Commitments)
+
+	// Round one:  every user deals.
+	for _, dealer := range dealers {
+		var (
+			out map[string]Deal
+			c   *Commitments
+			err error
+		)
+
+		if verifiable {
+			out, c, err = m.DealSharesVerifiable(rand.Reader, dealer, db)
+			commitments[dealer] = c
+		} else {
+			out, err = m.DealShares(field, rand.Reader, dealer, size, db)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for name, deal := range out {
+			inbox[name] = append(inbox[name], deal)
+		}
+	}
+
+	// Round two:  every user combines the deals they received.
+	shares := make(map[string][][]byte)
+
+	var joint *Commitments
+	for name, deals := range inbox {
+		var err error
+		if verifiable {
+			shares[name], joint, err = m.CombineDealsVerifiable(name, deals, commitments)
+		} else {
+			shares[name], err = m.CombineDeals(field, name, deals)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return shares, joint, nil
+}
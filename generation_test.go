@@ -0,0 +1,96 @@
+package msp
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGeneration(t *testing.T) {
+	db := &Database{"Alice": nil, "Bob": nil, "Carl": nil, "Dave": nil}
+	predicate, _ := StringToMSP("(2, (1, Alice, Bob), (2, Alice, Dave), Carl)")
+	dealers := []string{"Alice", "Bob", "Carl", "Dave"}
+
+	for _, size := range []int{7, 16, 32} {
+		shares, _, err := predicate.SimulateGeneration(nil, size, false, dealers, db)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Every authorized set recovers the same secret.
+		var sec []byte
+		for _, users := range [][]string{{"Carl", "Alice"}, {"Carl", "Bob"}, {"Alice", "Dave"}} {
+			subset := Database{}
+			for _, name := range users {
+				subset[name] = shares[name]
+			}
+
+			out, err := predicate.RecoverSecret(&subset)
+			if err != nil {
+				t.Fatal(err)
+			} else if len(out) != size {
+				t.Fatalf("Secret is %v bytes, not %v.", len(out), size)
+			} else if sec != nil && !bytes.Equal(sec, out) {
+				t.Fatalf("Size %v: Secrets derived differed:  %x %x", size, sec, out)
+			}
+			sec = out
+		}
+	}
+}
+
+func TestGenerationVerifiable(t *testing.T) {
+	db := &Database{"Alice": nil, "Bob": nil, "Carl": nil, "Dave": nil}
+	predicate, _ := StringToMSP("(2, (1, Alice, Bob), (2, Alice, Dave), Carl)")
+
+	shares, joint, err := predicate.SimulateGeneration(nil, 0, true, []string{"Alice", "Bob", "Carl"}, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sharesDb := Database(shares)
+	sec, err := predicate.RecoverSecretVerifiable(&sharesDb, joint)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The joint commitments' constant term is the public key of the secret.
+	x, y := elliptic.P256().ScalarBaseMult(sec)
+	if !bytes.Equal(joint.Coeffs[0], elliptic.MarshalCompressed(elliptic.P256(), x, y)) {
+		t.Fatalf("Joint commitments don't match the secret.")
+	}
+
+	// A dealer who sends a bad share is caught.
+	deals := make(map[string][]Deal)
+	commitments := make(map[string]*Commitments)
+	for _, dealer := range []string{"Alice", "Bob"} {
+		out, c, err := predicate.DealSharesVerifiable(rand.Reader, dealer, db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		commitments[dealer] = c
+
+		for name, deal := range out {
+			deals[name] = append(deals[name], deal)
+		}
+	}
+
+	deals["Carl"][1].Shares[0][31] ^= 1
+	if _, _, err := predicate.CombineDealsVerifiable("Carl", deals["Carl"], commitments); !errors.Is(err, ErrInvalidShare) {
+		t.Fatalf("Bad deal wasn't caught: %v", err)
+	}
+
+	if _, _, err := predicate.CombineDealsVerifiable("Alice", deals["Alice"][:1], commitments); err == nil {
+		t.Fatalf("Missing deal wasn't noticed!")
+	}
+
+	// Missing or malformed commitments are errors naming the dealer.
+	for _, c := range []*Commitments{nil, {}} {
+		commitments["Bob"] = c
+		if _, _, err := predicate.CombineDealsVerifiable("Alice", deals["Alice"], commitments); err == nil || !strings.Contains(err.Error(), "Bob") {
+			t.Fatalf("Bad commitments weren't noticed: %v", err)
+		}
+	}
+}
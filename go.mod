@@ -0,0 +1,3 @@
+module github.com/Bren2010/msp
+
+go 1.24
@@ -0,0 +1,196 @@
+package msp
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+// Every threshold gate is linear, so shares of secrets split by the same MSP
+// over the same field can be combined share-wise:  adding two users' shares
+// gives them shares of the sum of the secrets, and multiplying them by a
+// constant gives shares of the secret times the constant.  Each user can do it
+// alone, on their own shares, so the combined secret is never assembled.
+//
+// Raw shares don't say which predicate or field they were split with, so
+// AddShares and ScaleShares can only check that the shares have the right
+// shape.  Shares split by a different predicate that gives each user as many
+// shares, or over a different field with elements of the same size, are
+// combined into garbage without an error.  AddEnvelopes and ScaleEnvelopes
+// check the predicate and field recorded in share envelopes instead.
+
+// AddShares returns shares of the sum of the secrets that a and b are shares
+// of.  Both must have been split by this MSP over field, or over the default
+// field for their size if field is nil.  a and b may be the shares of just
+// some of the users, as long as they're the same users.
+func (m MSP) AddShares(field Field, a, b map[string][][]byte) (map[string][][]byte, error) {
+	field, err := m.checkShares(field, a)
+	if err != nil {
+		return nil, err
+	} else if _, err := m.checkShares(field, b); err != nil {
+		return nil, err
+	} else if len(a) != len(b) {
+		return nil, errors.New("Share sets are for different users.")
+	}
+
+	out := make(map[string][][]byte, len(a))
+	for name := range a {
+		if _, ok := b[name]; !ok {
+			return nil, errors.New("Share sets are for different users.")
+		}
+
+		if out[name], err = ApplyRefresh(field, a[name], b[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+// ScaleShares returns shares of c times the secret that shares are shares of.
+// c is one element of the field the secret was split over.
+func (m MSP) ScaleShares(field Field, c []byte, shares map[string][][]byte) (map[string][][]byte, error) {
+	field, err := m.checkShares(field, shares)
+	if err != nil {
+		return nil, err
+	} else if len(c) != field.Size() {
+		return nil, errors.New("Constant isn't one element of the field.")
+	} else if err := checkElems(field, c); err != nil {
+		return nil, err
+	}
+
+	scalar := field.Elem(c)
+
+	out := make(map[string][][]byte, len(shares))
+	for name, userShares := range shares {
+		out[name] = make([][]byte, len(userShares))
+
+		for i, share := range userShares {
+			out[name][i] = make([]byte, 0, len(share))
+			for k := 0; k < len(share); k += field.Size() {
+				out[name][i] = append(out[name][i], scalar.Mul(field.Elem(share[k:k+field.Size()])).e...)
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// AddEnvelopes is AddShares for marshalled envelopes, as returned by
+// DistributeEnvelopes.  Each of a and b must be envelopes of one split of this
+// predicate, and both splits must be over the same field, or ErrWrongSplit is
+// returned.  If macKey isn't nil, every envelope's MAC is checked with it and
+// the envelopes returned are signed with it.  They have a new split ID.
+func (m MSP) AddEnvelopes(a, b map[string][][]byte, macKey []byte) (map[string][][]byte, error) {
+	e, aShares, err := m.openEnvelopes(a, macKey)
+	if err != nil {
+		return nil, err
+	}
+
+	other, bShares, err := m.openEnvelopes(b, macKey)
+	if err != nil {
+		return nil, err
+	} else if other.Field != e.Field {
+		return nil, ErrWrongSplit
+	}
+
+	field, _ := FieldByID(e.Field)
+	sum, err := m.AddShares(field, aShares, bShares)
+	if err != nil {
+		return nil, err
+	}
+
+	return resealEnvelopes(e, sum, macKey)
+}
+
+// ScaleEnvelopes is ScaleShares for marshalled envelopes, as returned by
+// DistributeEnvelopes.  They must be envelopes of one split of this predicate,
+// or ErrWrongSplit is returned.  macKey is used as in AddEnvelopes.
+func (m MSP) ScaleEnvelopes(c []byte, envelopes map[string][][]byte, macKey []byte) (map[string][][]byte, error) {
+	e, shares, err := m.openEnvelopes(envelopes, macKey)
+	if err != nil {
+		return nil, err
+	}
+
+	field, _ := FieldByID(e.Field)
+	scaled, err := m.ScaleShares(field, c, shares)
+	if err != nil {
+		return nil, err
+	}
+
+	return resealEnvelopes(e, scaled, macKey)
+}
+
+// openEnvelopes unwraps envelopes of one split of this predicate, and returns
+// an envelope with the split's metadata along with the shares.
+func (m MSP) openEnvelopes(envelopes map[string][][]byte, macKey []byte) (Envelope, map[string][][]byte, error) {
+	edb := &envelopeDatabase{predicate: m.Hash(), macKey: macKey}
+
+	shares := make(map[string][][]byte, len(envelopes))
+	for name, raw := range envelopes {
+		userShares, err := edb.open(raw)
+		if err != nil {
+			return Envelope{}, nil, err
+		}
+		shares[name] = userShares
+	}
+
+	if !edb.seen {
+		return Envelope{}, nil, errors.New("No shares given.")
+	} else if _, ok := FieldByID(edb.field); !ok {
+		return Envelope{}, nil, errors.New("Unknown field in envelope.")
+	}
+
+	e := Envelope{Version: EnvelopeVersion, Field: edb.field, SplitID: edb.splitID, Predicate: edb.predicate}
+	return e, shares, nil
+}
+
+// resealEnvelopes wraps shares derived from another split in envelopes like e,
+// but with a new split ID so they can't be mixed up with the split's own.
+func resealEnvelopes(e Envelope, shares map[string][][]byte, macKey []byte) (map[string][][]byte, error) {
+	if _, err := io.ReadFull(rand.Reader, e.SplitID[:]); err != nil {
+		return nil, err
+	}
+
+	return sealEnvelopes(e, shares, macKey)
+}
+
+// checkShares checks that shares could have been split by this MSP over field,
+// or the default field for their size if it's nil, which is returned:  every
+// user is in the predicate with the right number of shares, and every share is
+// the same size and made of elements of the field.
+func (m MSP) checkShares(field Field, shares map[string][][]byte) (Field, error) {
+	users, size := Formatted(m).Users(), -1
+
+	for name, userShares := range shares {
+		if users[name] != len(userShares) {
+			return nil, errors.New("Shares weren't split by this predicate.")
+		}
+
+		for _, share := range userShares {
+			if size == -1 {
+				size = len(share)
+			} else if len(share) != size {
+				return nil, errors.New("Shares are different sizes!")
+			}
+		}
+	}
+	if size == -1 {
+		return nil, errors.New("No shares given.")
+	}
+
+	field, err := fieldOrDefault(field, size)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, userShares := range shares {
+		for _, share := range userShares {
+			if err := checkElems(field, share); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return field, nil
+}
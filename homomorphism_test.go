@@ -0,0 +1,132 @@
+package msp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestAddScaleShares(t *testing.T) {
+	db := &Database{"Alice": nil, "Bob": nil, "Carl": nil, "Dave": nil}
+	predicate, _ := StringToMSP("(2, (1, Alice, Bob), (2, Alice, Dave), Carl)")
+
+	for _, field := range []Field{Fields[1], Fields[16], P256Field} {
+		random := func() []byte {
+			buf := make([]byte, field.Size()+16)
+			rand.Read(buf)
+			return field.Elem(buf).Bytes()
+		}
+
+		x, y, c := field.Elem(random()), field.Elem(random()), field.Elem(random())
+
+		xShares, err := predicate.DistributeSharesInField(field, rand.Reader, x.Bytes(), db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		yShares, err := predicate.DistributeSharesInField(field, rand.Reader, y.Bytes(), db)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Each user can add their shares alone.
+		sum := make(map[string][][]byte)
+		for name := range xShares {
+			out, err := predicate.AddShares(field, map[string][][]byte{name: xShares[name]}, map[string][][]byte{name: yShares[name]})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sum[name] = out[name]
+		}
+
+		scaled, err := predicate.ScaleShares(field, c.Bytes(), sum)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		scaledDb := Database(scaled)
+		out, err := predicate.RecoverSecretInField(field, &scaledDb)
+		if err != nil {
+			t.Fatal(err)
+		} else if want := x.Add(y).Mul(c); !bytes.Equal(out, want.e) {
+			t.Fatalf("Wrong secret: %x, wanted %x", out, want.e)
+		}
+	}
+}
+
+func TestAddSharesMismatch(t *testing.T) {
+	db := &Database{"Alice": nil, "Bob": nil, "Carl": nil}
+	predicate, _ := StringToMSP("(2, (1, Alice, Bob), (2, Alice, Carl), Carl)")
+	other, _ := StringToMSP("(2, Alice, Bob, Carl)")
+
+	a, _ := predicate.DistributeShares(make([]byte, 16), db)
+	b, _ := predicate.DistributeShares(make([]byte, 32), db)
+	c, _ := other.DistributeShares(make([]byte, 16), db)
+
+	if _, err := predicate.AddShares(nil, a, b); err == nil {
+		t.Fatalf("Shares over different fields were added!")
+	} else if _, err := predicate.AddShares(nil, a, c); err == nil {
+		t.Fatalf("Shares of different predicates were added!")
+	} else if _, err := predicate.AddShares(nil, a, map[string][][]byte{"Alice": a["Alice"]}); err == nil {
+		t.Fatalf("Shares of different users were added!")
+	} else if _, err := predicate.ScaleShares(nil, []byte{1}, a); err == nil {
+		t.Fatalf("Constant from the wrong field was accepted!")
+	} else if _, err := predicate.ScaleShares(P256Field, P256Field.One().Bytes(), map[string][][]byte{"Alice": {bytes.Repeat([]byte{0xff}, 32), make([]byte, 32)}}); err == nil {
+		t.Fatalf("Shares that aren't field elements were accepted!")
+	}
+}
+
+func TestAddEnvelopes(t *testing.T) {
+	db := &Database{"Alice": nil, "Bob": nil, "Carl": nil}
+	predicate, _ := StringToMSP("(2, Alice, Bob, Carl)")
+	other, _ := StringToMSP("(3, Alice, Bob, Carl)")
+	key := []byte("mac key")
+
+	x, y, c := make([]byte, 16), make([]byte, 16), make([]byte, 16)
+	rand.Read(x)
+	rand.Read(y)
+	rand.Read(c)
+
+	a, _ := predicate.DistributeEnvelopes(nil, x, db, key)
+	b, _ := predicate.DistributeEnvelopes(nil, y, db, key)
+
+	sum, err := predicate.AddEnvelopes(a, b, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scaled, err := predicate.ScaleEnvelopes(c, sum, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scaledDb := Database(scaled)
+	out, err := predicate.RecoverSecretFromEnvelopes(&scaledDb, key)
+	if err != nil {
+		t.Fatal(err)
+	} else if want := Fields[16].Elem(x).Add(Fields[16].Elem(y)).Mul(Fields[16].Elem(c)); !bytes.Equal(out, want.e) {
+		t.Fatalf("Wrong secret: %x, wanted %x", out, want.e)
+	}
+
+	// Both predicates give every user one share, so raw shares can't be told
+	// apart, but envelopes can.
+	d, _ := other.DistributeEnvelopes(nil, y, db, key)
+	if _, err := predicate.AddEnvelopes(a, d, key); err != ErrWrongSplit {
+		t.Fatalf("Shares of different predicates were added: %v", err)
+	} else if _, err := other.AddEnvelopes(a, d, key); err != ErrWrongSplit {
+		t.Fatalf("Shares of different predicates were added: %v", err)
+	}
+
+	// GF(2^256) and P-256 elements are the same size.
+	e, _ := predicate.DistributeEnvelopes(Fields[32], make([]byte, 32), db, key)
+	f, _ := predicate.DistributeEnvelopes(P256Field, make([]byte, 32), db, key)
+	if _, err := predicate.AddEnvelopes(e, f, key); err != ErrWrongSplit {
+		t.Fatalf("Shares over different fields were added: %v", err)
+	}
+
+	// Envelopes from two splits can't be mixed in one operand.
+	mixed := map[string][][]byte{"Alice": a["Alice"], "Bob": b["Bob"]}
+	if _, err := predicate.ScaleEnvelopes(c, mixed, key); err != ErrWrongSplit {
+		t.Fatalf("Envelopes of different splits were scaled: %v", err)
+	} else if _, err := predicate.AddEnvelopes(a, b, []byte("wrong key")); err == nil {
+		t.Fatalf("Envelopes with bad MACs were added!")
+	}
+}
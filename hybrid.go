@@ -0,0 +1,87 @@
+package msp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+)
+
+// ErrAuthentication is returned when a hybrid ciphertext fails to authenticate
+// under the key recovered from the shares--either the ciphertext was modified
+// or the shares belong to a different split.
+var ErrAuthentication = errors.New("Ciphertext failed authentication.")
+
+// hybridKeySize is the size of the random key that is split by the MSP when
+// encrypting a secret of arbitrary length.
+const hybridKeySize = 32
+
+// DistributeSharesHybrid takes as input a secret of any length and a user
+// database.  A random key is split according to the access structure described
+// by the MSP and the secret is encrypted under it with AES-GCM.
+//
+// ct:     The encrypted secret, which may be stored publicly.
+// shares: Shares of the key, in the same form as returned by DistributeShares.
+func (m MSP) DistributeSharesHybrid(sec []byte, db UserDatabase) (ct []byte, shares map[string][][]byte, err error) {
+	key := make([]byte, hybridKeySize)
+	if _, err = rand.Read(key); err != nil {
+		return nil, nil, err
+	}
+
+	aead, err := newHybridAEAD(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+
+	shares, err = m.DistributeShares(key, db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ct = aead.Seal(nonce, nonce, sec, nil)
+	return ct, shares, nil
+}
+
+// RecoverSecretHybrid takes a ciphertext returned by DistributeSharesHybrid and
+// a user database storing shares of its key as input, and returns the original
+// secret.  If the ciphertext doesn't authenticate, ErrAuthentication is
+// returned.
+func (m MSP) RecoverSecretHybrid(ct []byte, db UserDatabase) ([]byte, error) {
+	key, err := m.RecoverSecret(db)
+	if err != nil {
+		return nil, err
+	} else if len(key) != hybridKeySize {
+		return nil, errors.New("Recovered key is the wrong size.")
+	}
+
+	aead, err := newHybridAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ct) < aead.NonceSize()+aead.Overhead() {
+		return nil, errors.New("Ciphertext is too short.")
+	}
+	nonce, ct := ct[:aead.NonceSize()], ct[aead.NonceSize():]
+
+	sec, err := aead.Open(nil, nonce, ct, nil)
+	if err != nil {
+		return nil, ErrAuthentication
+	}
+
+	return sec, nil
+}
+
+func newHybridAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,67 @@
+package msp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestHybrid(t *testing.T) {
+	db := &Database{
+		"Alice": [][]byte{},
+		"Bob":   [][]byte{},
+		"Carl":  [][]byte{},
+	}
+
+	predicate, _ := StringToMSP("(2, (1, Alice, Bob), Carl)")
+
+	for _, size := range []int{0, 1, 17, 1000} {
+		sec := make([]byte, size)
+		rand.Read(sec)
+
+		ct, shares, err := predicate.DistributeSharesHybrid(sec, db)
+		if err != nil {
+			t.Fatalf("Size %v: %v", size, err)
+		}
+
+		sharesDb := Database(shares)
+
+		out, err := predicate.RecoverSecretHybrid(ct, &sharesDb)
+		if err != nil {
+			t.Fatalf("Size %v: %v", size, err)
+		}
+
+		if !bytes.Equal(sec, out) {
+			t.Fatalf("Size %v: Secrets differed: %x %x", size, sec, out)
+		}
+
+		ct[len(ct)-1] ^= 1
+		if _, err := predicate.RecoverSecretHybrid(ct, &sharesDb); err != ErrAuthentication {
+			t.Fatalf("Size %v: Modified ciphertext wasn't rejected: %v", size, err)
+		}
+	}
+}
+
+func TestHybridWrongShares(t *testing.T) {
+	db := &Database{
+		"Alice": [][]byte{},
+		"Bob":   [][]byte{},
+	}
+
+	predicate, _ := StringToMSP("Alice & Bob")
+
+	ct, _, err := predicate.DistributeSharesHybrid([]byte("hello world"), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, shares, err := predicate.DistributeSharesHybrid([]byte("hello world"), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sharesDb := Database(shares)
+	if _, err := predicate.RecoverSecretHybrid(ct, &sharesDb); err != ErrAuthentication {
+		t.Fatalf("Shares from another split weren't rejected: %v", err)
+	}
+}
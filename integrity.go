@@ -0,0 +1,66 @@
+package msp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+)
+
+// ErrIntegrity is returned when a recovered secret doesn't match the tag
+// published when it was split--either shares were tampered with or they belong
+// to different splits.
+var ErrIntegrity = errors.New("Recovered secret doesn't match its tag.")
+
+// tagSaltSize is the size of the random salt at the start of a tag.
+const tagSaltSize = 32
+
+// DistributeSharesTagged splits a secret like DistributeShares, and also returns
+// a tag that RecoverSecretTagged uses to check the secret it recovers.  The tag
+// is a random salt followed by HMAC-SHA256(salt, secret).
+//
+// The tag can be published alongside the shares, but it lets anybody check a
+// guess of the secret, so it should only be used with secrets that can't be
+// guessed.
+func (m MSP) DistributeSharesTagged(sec []byte, db UserDatabase) (shares map[string][][]byte, tag []byte, err error) {
+	salt := make([]byte, tagSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, nil, err
+	}
+
+	shares, err = m.DistributeShares(sec, db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return shares, append(salt, computeTag(salt, sec)...), nil
+}
+
+// RecoverSecretTagged recovers a secret split by DistributeSharesTagged and
+// checks it against the tag.  If it doesn't match, ErrIntegrity is returned
+// instead of the secret.
+func (m MSP) RecoverSecretTagged(db UserDatabase, tag []byte) ([]byte, error) {
+	if len(tag) != tagSaltSize+sha256.Size {
+		return nil, errors.New("Tag is the wrong size.")
+	}
+
+	sec, err := m.RecoverSecret(db)
+	if err != nil {
+		return nil, err
+	}
+
+	salt, mac := tag[:tagSaltSize], tag[tagSaltSize:]
+	if !hmac.Equal(mac, computeTag(salt, sec)) {
+		return nil, ErrIntegrity
+	}
+
+	return sec, nil
+}
+
+func computeTag(salt, sec []byte) []byte {
+	h := hmac.New(sha256.New, salt)
+	h.Write(sec)
+
+	return h.Sum(nil)
+}
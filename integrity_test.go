@@ -0,0 +1,54 @@
+package msp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTagged(t *testing.T) {
+	db := &Database{
+		"Alice": [][]byte{},
+		"Bob":   [][]byte{},
+		"Carl":  [][]byte{},
+	}
+
+	sec := []byte("correct horse battery staple")
+	predicate, _ := StringToMSP("(2, (1, Alice, Bob), Carl)")
+
+	shares, tag, err := predicate.DistributeSharesTagged(sec, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sharesDb := Database(shares)
+	out, err := predicate.RecoverSecretTagged(&sharesDb, tag)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(sec, out) {
+		t.Fatalf("Secrets derived differed:  %x %x", sec, out)
+	}
+
+	// A tampered share is caught.
+	bad := append([]byte{}, shares["Carl"][0]...)
+	bad[3] ^= 0x80
+	sharesDb["Carl"] = [][]byte{bad}
+
+	if _, err := predicate.RecoverSecretTagged(&sharesDb, tag); err != ErrIntegrity {
+		t.Fatalf("Tampered share wasn't caught: %v", err)
+	}
+
+	// So is a share from a different split.
+	other, _, err := predicate.DistributeSharesTagged(sec, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sharesDb["Carl"] = other["Carl"]
+
+	if _, err := predicate.RecoverSecretTagged(&sharesDb, tag); err != ErrIntegrity {
+		t.Fatalf("Share from a different split wasn't caught: %v", err)
+	}
+
+	if _, err := predicate.RecoverSecretTagged(&sharesDb, tag[:10]); err == nil {
+		t.Fatalf("Truncated tag was accepted!")
+	}
+}
@@ -1,8 +1,6 @@
-// Matrix operations for elements in GF(2^128).
+// Matrix operations for elements of a Field.
 package msp
 
-import "bytes"
-
 type Matrix struct {
 	Field
 
@@ -28,12 +26,22 @@ func (m Matrix) Mul(r Row) Row {
 
 // Recovery returns the row vector that takes this matrix to the target vector [1 0 0 ... 0].
 func (m Matrix) Recovery() (Row, bool) {
+	target := m.Row(m.Width())
+	target.r[0] = m.One()
+
+	return m.Solve(target)
+}
+
+// Solve returns the row vector that takes this matrix to the target vector:  the coefficients of the linear
+// combination of m's rows that equals target.
+func (m Matrix) Solve(target Row) (Row, bool) {
 	a, b := m.Height(), m.Width()
-	zero := m.Zero()
 
 	// aug is the target vector.
 	aug := m.Row(a)
-	aug.r[0] = zero.One()
+	for i := range target.r {
+		aug.r[i] = target.r[i].Dup()
+	}
 
 	// Duplicate e away so we don't mutate it; transpose it at the same time.
 	f := m.Matrix(a, b)
@@ -44,28 +52,27 @@ func (m Matrix) Recovery() (Row, bool) {
 		}
 	}
 
+	// The matrix may be built from secret values, so the elimination below doesn't
+	// branch on them:  pivots are found with constant-time swaps, and rows are
+	// cancelled out whether or not they need to be.
 	for i := range f.m {
 		if i >= b { // The matrix is tall and thin--we've finished before exhausting all the rows.
 			break
 		}
 
-		// Find a row with a non-zero entry in the (row)th position
-		candId := -1
-		for j := range f.m[i:] {
-			if !bytes.Equal(f.m[j].r[i].e, zero.e) {
-				candId = j + i
-				break
-			}
+		// Find a row with a non-zero entry in the (row)th position and move it to
+		// the top.  Every row below is swapped up if the current pivot is zero.
+		for j := i + 1; j < len(f.m); j++ {
+			swap := f.m[i].r[i].isZero() & (1 - f.m[j].r[i].isZero())
+
+			f.m[i].condSwap(f.m[j], swap)
+			aug.r[i].condSwap(aug.r[j], swap)
 		}
 
-		if candId == -1 { // If we can't find one, fail and return our partial work.
+		if f.m[i].r[i].isZero() == 1 { // If we can't find one, fail and return our partial work.
 			return aug, false
 		}
 
-		// Move it to the top
-		f.m[i], f.m[candId] = f.m[candId], f.m[candId]
-		aug.r[i], aug.r[candId] = aug.r[candId], aug.r[i]
-
 		// Make the pivot 1.
 		fInv := f.m[i].r[i].Invert()
 
@@ -74,11 +81,11 @@ func (m Matrix) Recovery() (Row, bool) {
 
 		// Cancel out the (row)th position for every row above and below it.
 		for j := range f.m {
-			if j != i && !bytes.Equal(f.m[j].r[i].e, zero.e) {
+			if j != i {
 				c := f.m[j].r[i].Dup()
 
-				f.m[j].AddM(f.m[i].Mul(c))
-				aug.r[j].AddM(aug.r[i].Mul(c))
+				f.m[j].SubM(f.m[i].Mul(c))
+				aug.r[j].SubM(aug.r[i].Mul(c))
 			}
 		}
 	}
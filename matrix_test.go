@@ -6,17 +6,15 @@ import (
 )
 
 func TestRecovery(t *testing.T) {
+	fields := []Field{P256Field, Curve25519Field}
 	for _, field := range Fields {
-		// Generate the matrix.
-		height, width := 10, 10
-		M := field.Matrix(height, width)
+		fields = append(fields, field)
+	}
 
-		for i := range M.m {
-			for j := range M.m[i].r {
-				M.m[i].r[j].e[0] = byte(i + 1)
-				M.m[i].r[j] = M.m[i].r[j].Exp(j)
-			}
-		}
+	for _, field := range fields {
+		// Generate the matrix.
+		width := 10
+		M := field.Vandermonde([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, width)
 
 		// Find the recovery vector.
 		r, ok := M.Recovery()
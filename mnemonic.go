@@ -0,0 +1,236 @@
+package msp
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Mnemonics write a share envelope as words from wordList, so it can be copied
+// onto paper and typed back in.  Each word holds 11 bits of:
+//
+//	version (1) || field (1) || split ID (16) || index (varint) ||
+//	share length (varint) || share || MAC length (1) || MAC
+//
+// padded with zero bits, and followed by a checksum word:  the first 11 bits of
+// the SHA-256 hash of the words before it.  The predicate hash isn't included,
+// because whoever types the mnemonic back in has to know the predicate anyway.
+
+// ErrMnemonicChecksum is returned when every word of a mnemonic is in the word
+// list, but the checksum doesn't match--usually because words were skipped,
+// swapped, or mistyped as other words in the list.
+var ErrMnemonicChecksum = errors.New("Mnemonic checksum doesn't match.")
+
+// A MnemonicWordError is returned when a word of a mnemonic isn't in the word
+// list.
+type MnemonicWordError struct {
+	Position   int // Starting at 1.
+	Word       string
+	Suggestion string // The closest word in the list.
+}
+
+func (mwe MnemonicWordError) Error() string {
+	return fmt.Sprintf("Word %v (%q) isn't in the word list; did you mean %q?", mwe.Position, mwe.Word, mwe.Suggestion)
+}
+
+// wordIndex maps each word in wordList, and the first four letters of each
+// word, to its position.
+var wordIndex = func() map[string]int {
+	out := make(map[string]int, 2*len(wordList))
+	for i, word := range wordList {
+		out[word] = i
+		if len(word) > 4 {
+			out[word[:4]] = i
+		}
+	}
+
+	return out
+}()
+
+// Mnemonic returns the envelope as a space-separated list of words.  It fails
+// if the MAC is longer than 255 bytes.
+func (e Envelope) Mnemonic() (string, error) {
+	if len(e.MAC) > math.MaxUint8 {
+		return "", errors.New("Envelope's MAC is too long.")
+	}
+
+	payload := []byte{e.Version, byte(e.Field)}
+	payload = append(payload, e.SplitID[:]...)
+	payload = binary.AppendUvarint(payload, uint64(e.Index))
+	payload = binary.AppendUvarint(payload, uint64(len(e.Share)))
+	payload = append(payload, e.Share...)
+	payload = append(payload, byte(len(e.MAC)))
+	payload = append(payload, e.MAC...)
+
+	indices := toWordIndices(payload)
+	indices = append(indices, mnemonicChecksum(indices))
+
+	words := make([]string, len(indices))
+	for i, index := range indices {
+		words[i] = wordList[index]
+	}
+
+	return strings.Join(words, " "), nil
+}
+
+// DecodeMnemonic parses a mnemonic written by Envelope.Mnemonic for a share of
+// this predicate.  Case and whitespace don't matter, and like BIP-39, a word
+// only needs its first four letters to be right.  A MnemonicWordError is
+// returned for any other misspelled word, and ErrMnemonicChecksum if the words
+// are valid but wrong.
+func (m MSP) DecodeMnemonic(text string) (e Envelope, err error) {
+	words := strings.Fields(strings.ToLower(text))
+	if len(words) < 2 {
+		return e, errors.New("Mnemonic is too short.")
+	}
+
+	indices := make([]int, len(words))
+	for i, word := range words {
+		index, ok := wordIndex[word]
+		if !ok && len(word) > 4 {
+			index, ok = wordIndex[word[:4]]
+		}
+		if !ok {
+			return e, MnemonicWordError{i + 1, word, closestWord(word)}
+		}
+
+		indices[i] = index
+	}
+
+	last := len(indices) - 1
+	if mnemonicChecksum(indices[:last]) != indices[last] {
+		return e, ErrMnemonicChecksum
+	}
+
+	b, padding := fromWordIndices(indices[:last])
+	if padding != 0 {
+		return e, errors.New("Mnemonic has non-zero padding.")
+	}
+
+	if len(b) < 18 {
+		return e, errors.New("Mnemonic is too short.")
+	}
+
+	e.Version, e.Field = b[0], FieldID(b[1])
+	if e.Version != EnvelopeVersion {
+		return e, errors.New("Unknown envelope version.")
+	}
+	copy(e.SplitID[:], b[2:18])
+	e.Predicate = m.Hash()
+	b = b[18:]
+
+	index, n := binary.Uvarint(b)
+	if n <= 0 || index > 1<<32-1 {
+		return e, errors.New("Mnemonic is corrupt.")
+	}
+	e.Index, b = uint32(index), b[n:]
+
+	shareLen, n := binary.Uvarint(b)
+	if n <= 0 || len(b)-n < 1 || shareLen > uint64(len(b)-n-1) { // Leaves room for the MAC length.
+		return e, errors.New("Mnemonic is corrupt.")
+	}
+	b = b[n:]
+	e.Share, b = append([]byte{}, b[:shareLen]...), b[shareLen:]
+
+	// Up to one byte of padding can be left over after the MAC.
+	macLen := int(b[0])
+	if len(b) != 1+macLen && (len(b) != 2+macLen || b[len(b)-1] != 0) {
+		return e, errors.New("Mnemonic is the wrong length.")
+	}
+	if macLen > 0 {
+		e.MAC = append([]byte{}, b[1:1+macLen]...)
+	}
+
+	return e, nil
+}
+
+// toWordIndices splits b into 11-bit integers, padding the last with zeros.
+func toWordIndices(b []byte) []int {
+	out := make([]int, 0, (8*len(b)+10)/11)
+
+	acc, bits := 0, 0
+	for _, c := range b {
+		acc, bits = acc<<8|int(c), bits+8
+		if bits >= 11 {
+			bits -= 11
+			out = append(out, acc>>bits)
+			acc &= 1<<bits - 1
+		}
+	}
+	if bits > 0 {
+		out = append(out, acc<<(11-bits))
+	}
+
+	return out
+}
+
+// fromWordIndices is the inverse of toWordIndices.  It returns the leftover
+// bits that didn't fill a byte, which should be zero.
+func fromWordIndices(indices []int) (out []byte, padding int) {
+	acc, bits := 0, 0
+	for _, index := range indices {
+		acc, bits = acc<<11|index, bits+11
+		for bits >= 8 {
+			bits -= 8
+			out = append(out, byte(acc>>bits))
+			acc &= 1<<bits - 1
+		}
+	}
+
+	return out, acc
+}
+
+func mnemonicChecksum(indices []int) int {
+	h := sha256.New()
+	for _, index := range indices {
+		h.Write([]byte{byte(index >> 8), byte(index)})
+	}
+	sum := h.Sum(nil)
+
+	return int(sum[0])<<3 | int(sum[1]>>5)
+}
+
+// closestWord returns the word in wordList with the smallest edit distance to
+// word.
+func closestWord(word string) (best string) {
+	bestDist := -1
+	for _, candidate := range wordList {
+		if d := editDistance(word, candidate); bestDist < 0 || d < bestDist {
+			best, bestDist = candidate, d
+		}
+	}
+
+	return best
+}
+
+// editDistance returns the Damerau-Levenshtein distance between a and b,
+// counting swapped adjacent letters as one edit.
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(a)][len(b)]
+}
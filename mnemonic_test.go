@@ -0,0 +1,151 @@
+package msp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMnemonic(t *testing.T) {
+	db := &Database{
+		"Alice": [][]byte{},
+		"Bob":   [][]byte{},
+		"Carl":  [][]byte{},
+	}
+
+	predicate, _ := StringToMSP("(2, (1, Alice, Bob), Carl)")
+
+	for _, sec := range [][]byte{[]byte("short"), bytes.Repeat([]byte{0xff}, 16), make([]byte, 32), bytes.Repeat([]byte("long"), 100)} {
+		for _, macKey := range [][]byte{nil, []byte("key")} {
+			shares, err := predicate.DistributeEnvelopes(nil, sec, db, macKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Write every share down, then read it back in with a bit of sloppiness.
+			recovered := Database{}
+			for name, userShares := range shares {
+				for _, b := range userShares {
+					e, _ := UnmarshalEnvelope(b)
+
+					mnemonic, err := e.Mnemonic()
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					text := "  " + strings.ToUpper(strings.Replace(mnemonic, " ", "\n ", -1)) + "\n"
+					out, err := predicate.DecodeMnemonic(text)
+					if err != nil {
+						t.Fatalf("Length %v: %v", len(sec), err)
+					} else if !reflect.DeepEqual(e, out) {
+						t.Fatalf("Length %v: Envelope changed:\n%+v\n%+v", len(sec), e, out)
+					}
+
+					raw, err := out.Marshal()
+					if err != nil {
+						t.Fatal(err)
+					}
+					recovered[name] = append(recovered[name], raw)
+				}
+			}
+
+			out, err := predicate.RecoverSecretFromEnvelopes(&recovered, macKey)
+			if err != nil {
+				t.Fatal(err)
+			} else if !bytes.Equal(sec, out) {
+				t.Fatalf("Secrets derived differed:  %x %x", sec, out)
+			}
+		}
+	}
+}
+
+func TestMnemonicTypos(t *testing.T) {
+	predicate, _ := StringToMSP("(1, Alice, Bob)")
+	e := Envelope{Version: EnvelopeVersion, Field: FieldGF2_128, Index: 3, Share: bytes.Repeat([]byte{0x5a}, 16)}
+
+	mnemonic, err := e.Mnemonic()
+	if err != nil {
+		t.Fatal(err)
+	}
+	words := strings.Fields(mnemonic)
+
+	// Only the first four letters of a word matter.
+	fixed := append([]string{}, words...)
+	fixed[0] = words[0][:4]
+	if out, err := predicate.DecodeMnemonic(strings.Join(fixed, " ")); err != nil || !bytes.Equal(out.Share, e.Share) {
+		t.Fatalf("Abbreviated word was rejected: %v", err)
+	}
+
+	// A misspelled word is pointed out, with a suggestion.
+	typo := append([]string{}, words...)
+	typo[4] = words[4][1:]
+	_, err = predicate.DecodeMnemonic(strings.Join(typo, " "))
+	if mwe, ok := err.(MnemonicWordError); !ok || mwe.Position != 5 || mwe.Suggestion == "" {
+		t.Fatalf("Misspelled word wasn't detected: %v", err)
+	}
+
+	// Swapping two words, or dropping one, breaks the checksum.
+	swapped := append([]string{}, words...)
+	for i := range swapped {
+		if swapped[i] != swapped[i+1] {
+			swapped[i], swapped[i+1] = swapped[i+1], swapped[i]
+			break
+		}
+	}
+	if _, err := predicate.DecodeMnemonic(strings.Join(swapped, " ")); err != ErrMnemonicChecksum {
+		t.Fatalf("Swapped words weren't detected: %v", err)
+	}
+
+	if _, err := predicate.DecodeMnemonic(strings.Join(words[1:], " ")); err == nil {
+		t.Fatalf("Missing word wasn't detected!")
+	}
+
+	e.MAC = make([]byte, 256)
+	if _, err := e.Mnemonic(); err == nil {
+		t.Fatalf("Envelope with a 256-byte MAC was encoded!")
+	}
+}
+
+func TestMnemonicHugeLength(t *testing.T) {
+	predicate, _ := StringToMSP("(1, Alice, Bob)")
+
+	// A share length of 2^64-1, with a valid checksum.
+	payload := append([]byte{EnvelopeVersion, byte(FieldGF2_128)}, make([]byte, 16)...)
+	payload = binary.AppendUvarint(payload, 0)
+	payload = binary.AppendUvarint(payload, 1<<64-1)
+	payload = append(payload, 0, 0)
+
+	indices := toWordIndices(payload)
+	indices = append(indices, mnemonicChecksum(indices))
+
+	words := make([]string, len(indices))
+	for i, index := range indices {
+		words[i] = wordList[index]
+	}
+
+	if _, err := predicate.DecodeMnemonic(strings.Join(words, " ")); err == nil {
+		t.Fatalf("Mnemonic with a huge share length was accepted!")
+	}
+}
+
+func TestWordList(t *testing.T) {
+	if len(wordList) != 2048 || len(wordIndex) != 2*2048-countShortWords() {
+		t.Fatalf("Word list is the wrong size, or words share their first four letters.")
+	}
+
+	if editDistance("abandon", "abadnon") != 1 || closestWord("abadnon") != "abandon" {
+		t.Fatalf("Closest word is wrong.")
+	}
+}
+
+func countShortWords() (n int) {
+	for _, word := range wordList {
+		if len(word) <= 4 {
+			n++
+		}
+	}
+
+	return
+}
@@ -1,9 +1,11 @@
 package msp
 
 import (
+	"bytes"
 	"container/heap"
 	"crypto/rand"
 	"errors"
+	"io"
 	"strings"
 )
 
@@ -93,6 +95,8 @@ TopLoop:
 
 type MSP Formatted
 
+var errNotEnoughShares = errors.New("Not enough shares to recover.")
+
 func StringToMSP(pred string) (m MSP, err error) {
 	var f Formatted
 
@@ -151,44 +155,56 @@ func (m MSP) DerivePath(db UserDatabase) (ok bool, names []string, locs []int, t
 	return
 }
 
+// A splitter splits the secret of one threshold gate into one share for each of the gate's conditions.  DistributeShares
+// walks the MSP with a splitter, which is how different kinds of secret sharing reuse the same gate recursion.
+type splitter interface {
+	Split(m MSP, sec []byte) ([][]byte, error)
+
+	// Child returns the splitter for the nested threshold gate at condition i.
+	Child(i int) splitter
+}
+
+// A combiner is the inverse of a splitter:  it recovers the secret of one threshold gate from the shares of the
+// conditions at locs.
+type combiner interface {
+	Combine(m MSP, locs []int, shares [][]byte) ([]byte, error)
+
+	// Child returns the combiner for the nested threshold gate at condition i.
+	Child(i int) combiner
+}
+
 // DistributeShares takes as input a secret and a user database and returns secret shares according to access structure
 // described by the MSP.
+//
+// If there's a field in Fields the same size as the secret, the secret is split over it.  Otherwise, each byte of the
+// secret is split independently over GF(2^8).  Either way, shares are the same length as the secret.
 func (m MSP) DistributeShares(sec []byte, db UserDatabase) (map[string][][]byte, error) {
-	out := make(map[string][][]byte)
-
-	field, ok := Fields[len(sec)]
-	if !ok {
-		return nil, errors.New("No field for secret length")
-	}
+	return m.DistributeSharesWithRand(rand.Reader, sec, db)
+}
 
-	// Generate a Vandermonde matrix.
-	height, width := len(m.Conds), m.Min
-	M := field.Matrix(height, width)
+// DistributeSharesWithRand is the same as DistributeShares, but reads the randomness used to generate shares from the
+// given source.  An error reading from it is returned rather than ignored.
+func (m MSP) DistributeSharesWithRand(random io.Reader, sec []byte, db UserDatabase) (map[string][][]byte, error) {
+	return m.distribute(sec, db, fieldSplitter{random, nil})
+}
 
-	for i := range M.m {
-		for j := range M.m[i].r {
-			M.m[i].r[j].e[0] = byte(i + 1)
-			M.m[i].r[j] = M.m[i].r[j].Exp(j)
-		}
-	}
+// DistributeSharesInField is the same as DistributeSharesWithRand, but splits the secret over the given field instead
+// of choosing one by its length.  The secret must be one or more elements of the field, encoded one after another.
+func (m MSP) DistributeSharesInField(field Field, random io.Reader, sec []byte, db UserDatabase) (map[string][][]byte, error) {
+	return m.distribute(sec, db, fieldSplitter{random, field})
+}
 
-	// Convert secret vector.
-	s, buf := field.Row(width), make([]byte, len(sec))
-	for i := range s.r {
-		rand.Read(buf)
-		if i == 0 {
-			copy(buf, sec)
-		}
+func (m MSP) distribute(sec []byte, db UserDatabase, sp splitter) (map[string][][]byte, error) {
+	out := make(map[string][][]byte)
 
-		s.r[i] = field.Elem(buf)
+	shares, err := sp.Split(m, sec)
+	if err != nil {
+		return nil, err
 	}
 
-	// Calculate shares.
-	shares := M.Mul(s)
-
 	// Distribute the shares.
 	for i, cond := range m.Conds {
-		share := shares.r[i]
+		share := shares[i]
 
 		switch cond := cond.(type) {
 		case Name:
@@ -197,10 +213,10 @@ func (m MSP) DistributeShares(sec []byte, db UserDatabase) (map[string][][]byte,
 				return nil, errors.New("Unknown user in predicate.")
 			}
 
-			out[name] = append(out[name], share.Bytes())
+			out[name] = append(out[name], share)
 		case Formatted:
 			below := MSP(cond)
-			subOut, err := below.DistributeShares(share.Bytes(), db)
+			subOut, err := below.distribute(share, db, sp.Child(i))
 			if err != nil {
 				return out, err
 			}
@@ -217,21 +233,25 @@ func (m MSP) DistributeShares(sec []byte, db UserDatabase) (map[string][][]byte,
 // RecoverSecret takes a user database storing secret shares as input and returns the original secret.
 func (m MSP) RecoverSecret(db UserDatabase) ([]byte, error) {
 	cache := make(map[string][][]byte, 0) // Caches un-used shares for a user.
-	return m.recoverSecret(db, cache)
+	return m.recoverSecret(db, cache, fieldCombiner{})
+}
+
+// RecoverSecretInField recovers a secret that was split over the given field by DistributeSharesInField.
+func (m MSP) RecoverSecretInField(field Field, db UserDatabase) ([]byte, error) {
+	cache := make(map[string][][]byte, 0)
+	return m.recoverSecret(db, cache, fieldCombiner{field})
 }
 
-func (m MSP) recoverSecret(db UserDatabase, cache map[string][][]byte) ([]byte, error) {
+func (m MSP) recoverSecret(db UserDatabase, cache map[string][][]byte, cb combiner) ([]byte, error) {
 	var (
-		index  = []int{}  // Indexes where given shares were in the matrix.
-		shares = []Elem{} // Contains shares that will be used in reconstruction.
+		shares = [][]byte{} // Contains shares that will be used in reconstruction.
 	)
 
 	ok, names, locs, _ := m.DerivePath(db)
 	if !ok {
-		return nil, errors.New("Not enough shares to recover.")
+		return nil, errNotEnoughShares
 	}
 
-	var field Field
 	for _, name := range names {
 		if _, cached := cache[name]; !cached {
 			out, err := db.GetShare(name)
@@ -240,20 +260,11 @@ func (m MSP) recoverSecret(db UserDatabase, cache map[string][][]byte) ([]byte,
 			}
 
 			cache[name] = out
-
-			var ok bool
-			if field == nil {
-				field, ok = Fields[len(out[0])]
-				if !ok {
-					return nil, errors.New("No field for secret length")
-				}
-			}
 		}
 	}
 
 	for _, loc := range locs {
 		gate := m.Conds[loc]
-		index = append(index, loc+1)
 
 		switch gate := gate.(type) {
 		case Name:
@@ -261,28 +272,113 @@ func (m MSP) recoverSecret(db UserDatabase, cache map[string][][]byte) ([]byte,
 				return nil, errors.New("Predicate / database mismatch!")
 			}
 
-			shares = append(shares, field.Elem(cache[gate.string][gate.index]))
+			shares = append(shares, cache[gate.string][gate.index])
 
 		case Formatted:
-			share, err := MSP(gate).recoverSecret(db, cache)
+			share, err := MSP(gate).recoverSecret(db, cache, cb.Child(loc))
 			if err != nil {
 				return nil, err
 			}
 
-			shares = append(shares, field.Elem(share))
+			shares = append(shares, share)
 		}
 	}
 
-	// Generate the Vandermonde matrix specific to whichever users' shares we're using.
-	MSub := field.Matrix(m.Min, m.Min)
+	return cb.Combine(m, locs, shares)
+}
+
+// fieldSplitter splits secrets over a field:  the given one, or the one that matches their length as described by
+// DistributeShares.
+type fieldSplitter struct {
+	random io.Reader
+	field  Field
+}
+
+func (fs fieldSplitter) Split(m MSP, sec []byte) ([][]byte, error) {
+	field, err := fieldOrDefault(fs.field, len(sec))
+	if err != nil {
+		return nil, err
+	}
+
+	shares, _, err := m.splitBlocks(field, fs.random, sec)
+	return shares, err
+}
+
+func (fs fieldSplitter) Child(i int) splitter { return fs }
+
+// splitBlocks splits each field element of sec with its own random polynomial, and returns the shares of the top-level
+// threshold gate and the coefficients of each element's polynomial.
+func (m MSP) splitBlocks(field Field, random io.Reader, sec []byte) (shares [][]byte, coeffs []Row, err error) {
+	if err := m.validate(field.MaxPoints()); err != nil {
+		return nil, nil, err
+	} else if err := checkElems(field, sec); err != nil {
+		return nil, nil, err
+	}
+
+	// Generate a Vandermonde matrix.
+	height, width := len(m.Conds), m.Min
+	points := make([]int, height)
+	for i := range points {
+		points[i] = i
+	}
+
+	M := field.Vandermonde(points, width)
+
+	// Calculate shares, one field element of the secret at a time.
+	shares = make([][]byte, height)
+	for i := range shares {
+		shares[i] = make([]byte, 0, len(sec))
+	}
+
+	for k := 0; k < len(sec); k += field.Size() {
+		// Convert secret vector.
+		s := field.Row(width)
+		for i := range s.r {
+			buf, err := field.random(random)
+			if err != nil {
+				return nil, nil, err
+			}
+			if i == 0 {
+				buf = sec[k : k+field.Size()]
+			}
+
+			s.r[i] = field.Elem(buf)
+		}
+
+		for i, share := range M.Mul(s).r {
+			shares[i] = append(shares[i], share.e...)
+		}
+		coeffs = append(coeffs, s)
+	}
+
+	return shares, coeffs, nil
+}
+
+// fieldCombiner recovers secrets split by fieldSplitter.
+type fieldCombiner struct {
+	field Field
+}
 
-	for i := range MSub.m {
-		for j := range MSub.m[i].r {
-			MSub.m[i].r[j].e[0] = byte(index[i])
-			MSub.m[i].r[j] = MSub.m[i].r[j].Exp(j)
+func (fc fieldCombiner) Combine(m MSP, locs []int, shares [][]byte) ([]byte, error) {
+	size := len(shares[0])
+	for _, share := range shares {
+		if len(share) != size {
+			return nil, errors.New("Shares are different sizes!")
 		}
 	}
 
+	field, err := fieldOrDefault(fc.field, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.validate(field.MaxPoints()); err != nil {
+		return nil, err
+	}
+
+	// Generate the Vandermonde matrix specific to whichever users' shares we're using.
+	MSub := field.Vandermonde(locs, m.Min)
+
 	// Calculate the reconstruction vector and use it to recover the secret.
 	r, ok := MSub.Recovery()
 	if !ok {
@@ -290,8 +386,67 @@ func (m MSP) recoverSecret(db UserDatabase, cache map[string][][]byte) ([]byte,
 	}
 
 	// Compute dot product of the shares vector and the reconstruction vector to
-	// recover the secret.
-	s := Row{Field: field, r: shares}.DotProduct(r)
+	// recover the secret, one field element at a time.
+	sec, s := make([]byte, 0, size), field.Row(len(shares))
+	for k := 0; k < size; k += field.Size() {
+		for i, share := range shares {
+			s.r[i] = field.Elem(share[k : k+field.Size()])
+		}
+
+		sec = append(sec, s.DotProduct(r).e...)
+	}
+
+	return sec, nil
+}
+
+func (fc fieldCombiner) Child(i int) combiner { return fc }
+
+// validate checks that the top-level threshold gate of the MSP can be used to split secrets over a field with the given
+// number of evaluation points.
+func (m MSP) validate(maxPoints int) error {
+	if m.Min < 1 || m.Min > len(m.Conds) {
+		return errors.New("Threshold gate can never be satisfied.")
+	} else if len(m.Conds) > maxPoints {
+		return errors.New("Threshold gate has too many conditions for the field.")
+	}
+
+	return nil
+}
+
+// fieldFor returns the field that a secret or share of the given length is split over:  the field in Fields of exactly
+// that size, or GF(2^8) if there isn't one.
+func fieldFor(size int) (Field, error) {
+	if size == 0 {
+		return nil, errors.New("No field for secret length")
+	} else if field, ok := Fields[size]; ok {
+		return field, nil
+	}
+
+	return Fields[1], nil
+}
+
+// fieldOrDefault returns field if it isn't nil, and the field chosen by fieldFor otherwise.
+func fieldOrDefault(field Field, size int) (Field, error) {
+	if field == nil {
+		return fieldFor(size)
+	} else if size == 0 || size%field.Size() != 0 {
+		return nil, errors.New("Secret isn't a whole number of field elements.")
+	}
+
+	return field, nil
+}
+
+// checkElems checks that b is a sequence of canonically encoded elements of the field.
+func checkElems(field Field, b []byte) error {
+	if len(b) == 0 || len(b)%field.Size() != 0 {
+		return errors.New("Secret isn't a whole number of field elements.")
+	}
+
+	for k := 0; k < len(b); k += field.Size() {
+		if !bytes.Equal(field.Elem(b[k:k+field.Size()]).e, b[k:k+field.Size()]) {
+			return errors.New("Secret isn't an element of the field.")
+		}
+	}
 
-	return s.Bytes(), nil
+	return nil
 }
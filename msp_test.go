@@ -3,7 +3,10 @@ package msp
 import (
 	"bytes"
 	"crypto/rand"
+	"crypto/sha256"
 	"errors"
+	"fmt"
+	"reflect"
 	"testing"
 )
 
@@ -80,3 +83,198 @@ func TestMSP(t *testing.T) {
 		}
 	}
 }
+
+func TestMSPBytewise(t *testing.T) {
+	db := &Database{
+		"Alice": [][]byte{},
+		"Bob":   [][]byte{},
+		"Carl":  [][]byte{},
+	}
+
+	sec := make([]byte, 1000)
+	rand.Read(sec)
+
+	predicate, _ := StringToMSP("(2, (1, Alice, Bob), Carl)")
+
+	shares, err := predicate.DistributeShares(sec, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, userShares := range shares {
+		for _, share := range userShares {
+			if len(share) != len(sec) {
+				t.Fatalf("%v's share is %v bytes, not %v.", name, len(share), len(sec))
+			}
+		}
+	}
+
+	sharesDb := Database(shares)
+
+	out, err := predicate.RecoverSecret(&sharesDb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(sec, out) {
+		t.Fatalf("Secrets derived differed:  %x %x", sec, out)
+	}
+
+	if _, err := predicate.DistributeShares([]byte{}, db); err == nil {
+		t.Fatalf("Splitting an empty secret should fail!")
+	}
+}
+
+func TestMSPLargeGate(t *testing.T) {
+	db := Database{}
+	pred := "(3"
+	for i := 0; i < 1200; i++ {
+		name := fmt.Sprintf("User%v", i)
+		db[name] = [][]byte{}
+		pred += ", " + name
+	}
+	pred += ")"
+
+	predicate, err := StringToMSP(pred)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sec := make([]byte, 16)
+	rand.Read(sec)
+
+	shares, err := predicate.DistributeShares(sec, &db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every share must be distinct, or evaluation points have wrapped around.
+	seen := make(map[string]bool)
+	for _, userShares := range shares {
+		if seen[string(userShares[0])] {
+			t.Fatalf("Duplicate share found!")
+		}
+		seen[string(userShares[0])] = true
+	}
+
+	sharesDb := Database{}
+	for _, name := range []string{"User254", "User255", "User1199"} {
+		sharesDb[name] = shares[name]
+	}
+
+	out, err := predicate.RecoverSecret(&sharesDb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(sec, out) {
+		t.Fatalf("Secrets derived differed:  %x %x", sec, out)
+	}
+
+	// Byte-wise secrets are split over GF(2^8), which only has 255 evaluation
+	// points.
+	if _, err := predicate.DistributeShares(make([]byte, 20), &db); err == nil {
+		t.Fatalf("Gate with more conditions than the field supports wasn't rejected!")
+	}
+}
+
+// testReader is a deterministic source of randomness for generating test vectors:  SHA-256 in counter mode.
+type testReader struct {
+	seed    string
+	counter int
+	buf     []byte
+}
+
+func (tr *testReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(tr.buf) == 0 {
+			h := sha256.Sum256([]byte(fmt.Sprintf("%v/%v", tr.seed, tr.counter)))
+			tr.buf, tr.counter = h[:], tr.counter+1
+		}
+
+		c := copy(p[n:], tr.buf)
+		tr.buf, n = tr.buf[c:], n+c
+	}
+
+	return n, nil
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) { return 0, errors.New("RNG failure") }
+
+func TestMSPWithRand(t *testing.T) {
+	db := &Database{
+		"Alice": [][]byte{},
+		"Bob":   [][]byte{},
+		"Carl":  [][]byte{},
+	}
+
+	sec := []byte("0123456789abcdef")
+	predicate, _ := StringToMSP("(2, (1, Alice, Bob), Carl)")
+
+	shares1, err := predicate.DistributeSharesWithRand(&testReader{seed: "test"}, sec, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shares2, err := predicate.DistributeSharesWithRand(&testReader{seed: "test"}, sec, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(shares1, shares2) {
+		t.Fatalf("Same randomness gave different shares! %x %x", shares1, shares2)
+	}
+
+	sharesDb := Database(shares1)
+	out, err := predicate.RecoverSecret(&sharesDb)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(sec, out) {
+		t.Fatalf("Secrets derived differed:  %x %x", sec, out)
+	}
+
+	if _, err := predicate.DistributeSharesWithRand(failingReader{}, sec, db); err == nil {
+		t.Fatalf("RNG failure wasn't returned!")
+	}
+}
+
+func TestMSPPrimeField(t *testing.T) {
+	db := &Database{
+		"Alice": [][]byte{},
+		"Bob":   [][]byte{},
+		"Carl":  [][]byte{},
+	}
+
+	predicate, _ := StringToMSP("(2, (1, Alice, Bob), (2, Alice, Bob, Carl), Carl)")
+
+	for _, field := range []Field{P256Field, Curve25519Field, Fields[16]} {
+		sec := field.Point(12345).Bytes()
+		sec = append(sec, field.Point(67890).Bytes()...)
+
+		shares, err := predicate.DistributeSharesInField(field, rand.Reader, sec, db)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sharesDb := Database(shares)
+		out, err := predicate.RecoverSecretInField(field, &sharesDb)
+		if err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(sec, out) {
+			t.Fatalf("Secrets derived differed:  %x %x", sec, out)
+		}
+	}
+
+	// Secrets must be canonical elements of the field.
+	bad := bytes.Repeat([]byte{0xff}, 32)
+	if _, err := predicate.DistributeSharesInField(P256Field, rand.Reader, bad, db); err == nil {
+		t.Fatalf("Secret larger than the modulus was accepted!")
+	}
+
+	if _, err := predicate.DistributeSharesInField(P256Field, rand.Reader, bad[:31], db); err == nil {
+		t.Fatalf("Secret of the wrong length was accepted!")
+	}
+}
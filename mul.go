@@ -0,0 +1,159 @@
+package msp
+
+import "sync"
+
+// Multiplication in GF(2^n) is done on 64-bit words, four bits of e at a time:
+//
+//     acc = 0
+//     for each 4-bit window w of e, from most to least significant:
+//         acc = acc * x^4 mod M(x)
+//         acc = acc + w(x) * f
+//
+// Multiplying by x^4 shifts four bits off the top of acc, which are reduced by
+// looking them up in a table of t(x) * x^n mod M(x) precomputed for each field.
+// The sixteen multiples w(x) * f are computed once per multiplication.
+//
+// Both e and f may be secret, so none of this branches on or indexes memory by
+// their bits.  Table lookups read every entry and keep the right one with a
+// mask, and reductions are masked rather than conditional.
+
+// mulTable holds the precomputed reduction table for one field.
+type mulTable struct {
+	words  int          // Number of 64-bit words in an element.
+	bits   int          // Degree of the field's modulus.
+	reduce [16][]uint64 // reduce[t] = t(x) * x^n mod M(x)
+}
+
+var mulTables sync.Map // string(BinaryField) -> *mulTable
+
+// table returns the field's reduction table, building it on first use.
+func (f BinaryField) table() *mulTable {
+	if t, ok := mulTables.Load(string(f)); ok {
+		return t.(*mulTable)
+	}
+
+	t := &mulTable{words: (f.Size() + 7) / 8, bits: f.BitSize()}
+
+	// x^n = m(x) mod M(x), where m(x) is the low part of the modulus stored in
+	// the field.  Each higher power is one more multiplication by x.
+	xn := [4][]uint64{f.toWords(f)}
+	for i := 1; i < 4; i++ {
+		xn[i] = make([]uint64, t.words)
+		copy(xn[i], xn[i-1])
+		t.mulX(xn[i], xn[0])
+	}
+
+	for i := range t.reduce {
+		t.reduce[i] = make([]uint64, t.words)
+
+		for j := 0; j < 4; j++ {
+			if i>>uint(j)&1 == 1 {
+				xorWords(t.reduce[i], xn[j])
+			}
+		}
+	}
+
+	actual, _ := mulTables.LoadOrStore(string(f), t)
+	return actual.(*mulTable)
+}
+
+// mul returns a*b mod M(x).
+func (t *mulTable) mul(a, b []uint64) []uint64 {
+	// window[w] = w(x) * b mod M(x)
+	var window [16][]uint64
+	window[0] = make([]uint64, t.words)
+	window[1] = b
+
+	for i := 2; i < 16; i += 2 {
+		window[i] = make([]uint64, t.words)
+		copy(window[i], window[i/2])
+		t.mulX(window[i], t.reduce[1])
+
+		window[i+1] = make([]uint64, t.words)
+		copy(window[i+1], window[i])
+		xorWords(window[i+1], b)
+	}
+
+	acc := make([]uint64, t.words)
+	for i := t.bits/4 - 1; i >= 0; i-- {
+		lookup(acc, &t.reduce, t.shift4(acc))
+		lookup(acc, &window, a[i/16]>>(uint(i)%16*4)&0xf)
+	}
+
+	return acc
+}
+
+// lookup adds table[i] to a, touching every entry of the table so that the
+// memory access pattern doesn't depend on i.
+func lookup(a []uint64, table *[16][]uint64, i uint64) {
+	for j := range table {
+		// mask is all ones if i == j and all zeros otherwise.
+		mask := (i ^ uint64(j)) - 1
+		mask = -(mask >> 63)
+
+		for k := range a {
+			a[k] ^= table[j][k] & mask
+		}
+	}
+}
+
+// mulX multiplies a by x in place, where xn = x^n mod M(x).
+func (t *mulTable) mulX(a, xn []uint64) {
+	top := (t.bits - 1) / 64
+	carry := a[top] >> (uint(t.bits-1) % 64) & 1
+
+	for i := top; i > 0; i-- {
+		a[i] = a[i]<<1 | a[i-1]>>63
+	}
+	a[0] <<= 1
+	t.mask(a)
+
+	for i := range a {
+		a[i] ^= xn[i] & -carry
+	}
+}
+
+// shift4 multiplies a by x^4 in place without reducing, and returns the four
+// bits that were shifted off the top.
+func (t *mulTable) shift4(a []uint64) uint64 {
+	top := (t.bits - 4) / 64
+	out := a[top] >> (uint(t.bits-4) % 64) & 0xf
+
+	for i := top; i > 0; i-- {
+		a[i] = a[i]<<4 | a[i-1]>>60
+	}
+	a[0] <<= 4
+	t.mask(a)
+
+	return out
+}
+
+// mask clears any bits of a at or above x^n.
+func (t *mulTable) mask(a []uint64) {
+	if rem := uint(t.bits) % 64; rem != 0 {
+		a[len(a)-1] &= 1<<rem - 1
+	}
+}
+
+// toWords converts a little-endian byte string into 64-bit words.
+func (f BinaryField) toWords(b []byte) []uint64 {
+	out := make([]uint64, (f.Size()+7)/8)
+	for i := 0; i < f.Size() && i < len(b); i++ {
+		out[i/8] |= uint64(b[i]) << (uint(i) % 8 * 8)
+	}
+
+	return out
+}
+
+// fromWords converts 64-bit words back into a little-endian byte string.
+func (f BinaryField) fromWords(a []uint64, b []byte) {
+	for i := range b {
+		b[i] = byte(a[i/8] >> (uint(i) % 8 * 8))
+	}
+}
+
+func xorWords(a, b []uint64) {
+	for i := range a {
+		a[i] ^= b[i]
+	}
+}
@@ -3,6 +3,7 @@ package msp
 import (
 	"bytes"
 	"crypto/rand"
+	"math/big"
 	"testing"
 )
 
@@ -58,3 +59,175 @@ func TestFieldElemInvert(t *testing.T) {
 		}
 	}
 }
+
+func TestGF256(t *testing.T) {
+	// Compare GF(2^8) against schoolbook multiplication with the AES polynomial.
+	mul := func(a, b byte) (out byte) {
+		for ; b > 0; b >>= 1 {
+			if b&1 == 1 {
+				out ^= a
+			}
+
+			carry := a & 0x80
+			a <<= 1
+			if carry != 0 {
+				a ^= 0x1b
+			}
+		}
+		return
+	}
+
+	field := Fields[1]
+	for a := 0; a < 256; a++ {
+		x := field.Elem([]byte{byte(a)})
+
+		for b := 0; b < 256; b++ {
+			if got, want := x.Mul(field.Elem([]byte{byte(b)})).e[0], mul(byte(a), byte(b)); got != want {
+				t.Fatalf("%x * %x = %x, wanted %x", a, b, got, want)
+			}
+		}
+
+		if a != 0 && x.Mul(x.Invert()).e[0] != 1 {
+			t.Fatalf("Inverse of %x is wrong!", a)
+		}
+	}
+}
+
+func TestFieldPoint(t *testing.T) {
+	for _, field := range Fields {
+		seen := make(map[string]bool)
+		for i := 0; i < 255; i++ {
+			x := field.Point(i)
+			if seen[string(x.e)] || bytes.Equal(x.e, field.Zero().e) {
+				t.Fatalf("Evaluation point %v is repeated or zero!", i)
+			}
+			seen[string(x.e)] = true
+		}
+	}
+
+	if Fields[1].MaxPoints() != 255 {
+		t.Fatalf("GF(2^8) should have 255 evaluation points, not %v.", Fields[1].MaxPoints())
+	}
+
+	x := Fields[16].Point(1000)
+	if x.e[0] != 0xe9 || x.e[1] != 0x03 {
+		t.Fatalf("Evaluation point 1000 was encoded wrong: %x", x.e)
+	}
+}
+
+// slowMul is the bit-serial shift-and-add multiplication that Mul replaced, kept
+// as a reference implementation.
+func slowMul(e, f Elem) Elem {
+	elem, modulus := e.Zero(), e.Field.(BinaryField)
+
+	for i := 0; i < modulus.BitSize(); i++ { // Foreach bit e_i in e:
+		if (e.e[i/8]>>(uint(i)%8))&1 == 1 { // where e_i equals 1:
+			temp := f.Dup() // Multiply f * x^i mod M(x):
+
+			for j := 0; j < i; j++ { // Multiply f by x mod M(x), i times.
+				carry := false
+				for k := 0; k < temp.Size(); k++ {
+					nextCarry := (temp.e[k] >= 128)
+					temp.e[k] = (temp.e[k] << 1)
+					if carry {
+						temp.e[k]++
+					}
+					carry = nextCarry
+				}
+
+				if carry {
+					for k := range modulus {
+						temp.e[k] ^= modulus[k]
+					}
+				}
+			}
+
+			elem.AddM(temp) // Add f * x^i to the output
+		}
+	}
+
+	return elem
+}
+
+func TestFieldElemMultiplication(t *testing.T) {
+	fields := []Field{Fields[16], Fields[32], BuildField(3, 27), BuildField(12, 9, 4)}
+
+	for _, field := range fields {
+		for i := 0; i < 100; i++ {
+			x, y := field.Zero(), field.Zero()
+			rand.Read(x.e)
+			rand.Read(y.e)
+
+			if xy, want := x.Mul(y), slowMul(x, y); !bytes.Equal(xy.Bytes(), want.Bytes()) {
+				t.Fatalf("Multiplication failed!\nx = %x\ny = %x\nx*y = %x\nwanted %x", x.e, y.e, xy.e, want.e)
+			}
+		}
+	}
+}
+
+func benchmarkMul(b *testing.B, field Field, mul func(e, f Elem) Elem) {
+	x, y := field.Zero(), field.Zero()
+	rand.Read(x.e)
+	rand.Read(y.e)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mul(x, y)
+	}
+}
+
+func BenchmarkMul16(b *testing.B)     { benchmarkMul(b, Fields[16], Elem.Mul) }
+func BenchmarkMul32(b *testing.B)     { benchmarkMul(b, Fields[32], Elem.Mul) }
+func BenchmarkSlowMul16(b *testing.B) { benchmarkMul(b, Fields[16], slowMul) }
+func BenchmarkSlowMul32(b *testing.B) { benchmarkMul(b, Fields[32], slowMul) }
+
+func benchmarkInvert(b *testing.B, field Field) {
+	x := field.Zero()
+	rand.Read(x.e)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		x.Invert()
+	}
+}
+
+func BenchmarkInvert16(b *testing.B) { benchmarkInvert(b, Fields[16]) }
+func BenchmarkInvert32(b *testing.B) { benchmarkInvert(b, Fields[32]) }
+
+func TestPrimeField(t *testing.T) {
+	for _, field := range []PrimeField{P256Field, Curve25519Field, NewPrimeField(big.NewInt(65537))} {
+		buf := make([]byte, field.Size()+16)
+		rand.Read(buf)
+		x := field.Elem(buf)
+
+		if field.Modulus().Cmp(new(big.Int).SetBytes(x.e)) <= 0 {
+			t.Fatalf("Element wasn't reduced: %x", x.e)
+		}
+
+		if !bytes.Equal(x.Mul(field.One()).Bytes(), x.Bytes()) {
+			t.Fatalf("Multiplication by 1 failed!")
+		}
+
+		if !bytes.Equal(x.Mul(x.Invert()).Bytes(), field.One().Bytes()) {
+			t.Fatalf("Multiplication by inverse failed!")
+		}
+
+		if !bytes.Equal(x.Sub(x).Bytes(), field.Zero().Bytes()) || !bytes.Equal(x.Sub(x.One()).Add(x.One()).Bytes(), x.Bytes()) {
+			t.Fatalf("Subtraction failed!")
+		}
+
+		// -1 is p - 1.
+		minusOne := field.Zero().Sub(field.One())
+		if new(big.Int).Add(new(big.Int).SetBytes(minusOne.e), big.NewInt(1)).Cmp(field.Modulus()) != 0 {
+			t.Fatalf("-1 was wrong: %x", minusOne.e)
+		}
+	}
+
+	if Curve25519Field.Size() != 32 || Curve25519Field.Modulus().BitLen() != 253 {
+		t.Fatalf("Curve25519 order is wrong: %v", Curve25519Field.Modulus())
+	}
+
+	if NewPrimeField(big.NewInt(65537)).MaxPoints() != 65536 {
+		t.Fatalf("Wrong number of evaluation points for GF(65537).")
+	}
+}
@@ -0,0 +1,183 @@
+package msp
+
+import (
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// PasswordIterations is the number of PBKDF2-HMAC-SHA256 iterations used to
+// derive keys from passwords.
+const PasswordIterations = 600000
+
+// maxPasswordIterations bounds the iterations read from stored shares, so a
+// tampered record can't make decryption run forever.
+const maxPasswordIterations = 16 * PasswordIterations
+
+// ErrWrongPassword is returned when a user's shares don't decrypt under the
+// password they gave.
+var ErrWrongPassword = errors.New("Wrong password.")
+
+// A PasswordPrompt asks the named user for their password.
+type PasswordPrompt func(name string) (string, error)
+
+// EncryptedShares are one user's shares, encrypted with AES-256-GCM under a key
+// derived from their password with PBKDF2.  The user's name is authenticated
+// along with the shares, so encrypted shares can't be passed off as somebody
+// else's.
+type EncryptedShares struct {
+	Salt       []byte
+	Iterations int
+	Ciphertext []byte // The nonce followed by the sealed shares.
+}
+
+// EncryptShares encrypts the named user's shares under their password, with a
+// fresh random salt.
+func EncryptShares(name, password string, shares [][]byte) (EncryptedShares, error) {
+	es := EncryptedShares{Salt: make([]byte, 16), Iterations: PasswordIterations}
+	if _, err := rand.Read(es.Salt); err != nil {
+		return es, err
+	}
+
+	aead, err := es.aead(password)
+	if err != nil {
+		return es, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return es, err
+	}
+
+	es.Ciphertext = aead.Seal(nonce, nonce, marshalShares(shares), []byte(name))
+	return es, nil
+}
+
+// Decrypt decrypts the named user's shares with their password.
+// ErrWrongPassword is returned if the password or name is wrong, or the
+// ciphertext has been modified.
+func (es EncryptedShares) Decrypt(name, password string) ([][]byte, error) {
+	aead, err := es.aead(password)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(es.Ciphertext) < aead.NonceSize()+aead.Overhead() {
+		return nil, errors.New("Ciphertext is too short.")
+	}
+	nonce, ct := es.Ciphertext[:aead.NonceSize()], es.Ciphertext[aead.NonceSize():]
+
+	pt, err := aead.Open(nil, nonce, ct, []byte(name))
+	if err != nil {
+		return nil, ErrWrongPassword
+	}
+
+	return unmarshalShares(pt)
+}
+
+func (es EncryptedShares) aead(password string) (cipher.AEAD, error) {
+	if es.Iterations < 1 || es.Iterations > maxPasswordIterations {
+		return nil, errors.New("Invalid number of iterations.")
+	}
+
+	key, err := pbkdf2.Key(sha256.New, password, es.Salt, es.Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	return newHybridAEAD(key)
+}
+
+// A PasswordDatabase is a UserDatabase of password-encrypted shares.  Checking
+// whether a user's shares are available doesn't need their password, so the
+// prompt is only called from GetShare:  recovering a secret asks for exactly
+// the passwords of the users that DerivePath picks.
+type PasswordDatabase struct {
+	Users  map[string]EncryptedShares
+	Prompt PasswordPrompt
+}
+
+// NewPasswordDatabase encrypts the shares returned by DistributeShares under
+// each user's password, which is asked for with prompt.  The same prompt is
+// used to decrypt them later.
+func NewPasswordDatabase(shares map[string][][]byte, prompt PasswordPrompt) (*PasswordDatabase, error) {
+	pd := &PasswordDatabase{Users: make(map[string]EncryptedShares), Prompt: prompt}
+
+	for name, userShares := range shares {
+		password, err := prompt(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if pd.Users[name], err = EncryptShares(name, password, userShares); err != nil {
+			return nil, err
+		}
+	}
+
+	return pd, nil
+}
+
+func (pd *PasswordDatabase) ValidUser(name string) bool {
+	_, ok := pd.Users[name]
+	return ok
+}
+
+func (pd *PasswordDatabase) CanGetShare(name string) bool {
+	_, ok := pd.Users[name]
+	return ok
+}
+
+// GetShare asks the user for their password and decrypts their shares.
+func (pd *PasswordDatabase) GetShare(name string) ([][]byte, error) {
+	es, ok := pd.Users[name]
+	if !ok {
+		return nil, errors.New("Not found!")
+	}
+
+	password, err := pd.Prompt(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return es.Decrypt(name, password)
+}
+
+// marshalShares encodes a user's shares as a count followed by each share with
+// its length, as 4-byte big-endian integers.
+func marshalShares(shares [][]byte) []byte {
+	out := binary.BigEndian.AppendUint32(nil, uint32(len(shares)))
+	for _, share := range shares {
+		out = binary.BigEndian.AppendUint32(out, uint32(len(share)))
+		out = append(out, share...)
+	}
+
+	return out
+}
+
+func unmarshalShares(b []byte) ([][]byte, error) {
+	if len(b) < 4 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	count := binary.BigEndian.Uint32(b)
+	b = b[4:]
+
+	out := [][]byte{}
+	for i := uint32(0); i < count; i++ {
+		if len(b) < 4 || uint64(len(b)-4) < uint64(binary.BigEndian.Uint32(b)) {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		n := binary.BigEndian.Uint32(b)
+		out, b = append(out, append([]byte{}, b[4:4+n]...)), b[4+n:]
+	}
+
+	if len(b) != 0 {
+		return nil, errors.New("Trailing data after shares.")
+	}
+
+	return out, nil
+}
@@ -0,0 +1,92 @@
+package msp
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestPasswordDatabase(t *testing.T) {
+	passwords := map[string]string{"Alice": "correct horse", "Bob": "battery staple", "Carl": "hunter2"}
+	prompted := []string{}
+
+	prompt := func(name string) (string, error) {
+		prompted = append(prompted, name)
+		return passwords[name], nil
+	}
+
+	db := &Database{"Alice": nil, "Bob": nil, "Carl": nil}
+	sec := []byte("attack at dawn")
+	predicate, _ := StringToMSP("(2, (1, Alice, Bob), Carl)")
+
+	shares, err := predicate.DistributeShares(sec, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pd, err := NewPasswordDatabase(shares, prompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !pd.CanGetShare("Alice") || pd.CanGetShare("Dave") {
+		t.Fatalf("CanGetShare was wrong.")
+	}
+
+	// Recovery only asks for the passwords of the users it needs.
+	prompted = nil
+	out, err := predicate.RecoverSecret(pd)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(sec, out) {
+		t.Fatalf("Secrets derived differed:  %x %x", sec, out)
+	} else if len(prompted) != 2 {
+		t.Fatalf("Asked for too many passwords: %v", prompted)
+	}
+
+	// Salts are per-user, and shares are bound to their user.
+	if bytes.Equal(pd.Users["Alice"].Salt, pd.Users["Bob"].Salt) {
+		t.Fatalf("Users share a salt!")
+	}
+
+	passwords["Alice"] = "wrong"
+	if _, err := pd.GetShare("Alice"); err != ErrWrongPassword {
+		t.Fatalf("Wrong password was accepted: %v", err)
+	}
+
+	pd.Users["Alice"] = pd.Users["Carl"]
+	passwords["Alice"] = passwords["Carl"]
+	if _, err := pd.GetShare("Alice"); err != ErrWrongPassword {
+		t.Fatalf("Another user's shares were accepted: %v", err)
+	}
+
+	tampered := pd.Users["Bob"]
+	tampered.Iterations = math.MaxInt
+	if _, err := tampered.Decrypt("Bob", passwords["Bob"]); err == nil || err == ErrWrongPassword {
+		t.Fatalf("Huge iteration count was accepted: %v", err)
+	}
+
+	errPrompt := errors.New("Cancelled.")
+	pd.Prompt = func(string) (string, error) { return "", errPrompt }
+	if _, err := pd.GetShare("Bob"); err != errPrompt {
+		t.Fatalf("Prompt error wasn't returned: %v", err)
+	}
+}
+
+func TestMarshalShares(t *testing.T) {
+	for _, shares := range [][][]byte{{}, {{}}, {[]byte("a"), []byte("bc"), {}}} {
+		out, err := unmarshalShares(marshalShares(shares))
+		if err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(out, shares) {
+			t.Fatalf("Shares changed: %q %q", shares, out)
+		}
+
+		b := marshalShares(shares)
+		if _, err := unmarshalShares(b[:len(b)-1]); err == nil {
+			t.Fatalf("Truncated shares were accepted!")
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package msp
+
+import (
+	"crypto/elliptic"
+	"io"
+	"math"
+	"math/big"
+)
+
+// PrimeField is GF(p), the integers modulo a prime p.  Elements are encoded as
+// big-endian integers the same length as p.  Arithmetic is done with math/big
+// and, unlike BinaryField, isn't constant-time.
+type PrimeField struct {
+	p    *big.Int
+	size int
+}
+
+var (
+	// P256Field is the field of scalars of the P-256 group.
+	P256Field = NewPrimeField(elliptic.P256().Params().N)
+
+	// Curve25519Field is the field of scalars of the prime-order subgroup of
+	// Curve25519:  2^252 + 27742317777372353535851937790883648493.
+	Curve25519Field = NewPrimeField(curve25519Order())
+)
+
+func curve25519Order() *big.Int {
+	l, _ := new(big.Int).SetString("27742317777372353535851937790883648493", 10)
+	return l.Add(l, new(big.Int).Lsh(big.NewInt(1), 252))
+}
+
+// NewPrimeField returns the field of integers modulo p.  The caller is
+// responsible for making sure p is prime.
+func NewPrimeField(p *big.Int) PrimeField {
+	return PrimeField{
+		p:    new(big.Int).Set(p),
+		size: (p.BitLen() + 7) / 8,
+	}
+}
+
+// Elem interprets val as a big-endian integer and reduces it modulo p.
+func (f PrimeField) Elem(val []byte) Elem {
+	return f.fromInt(new(big.Int).SetBytes(val))
+}
+
+func (f PrimeField) Row(width int) Row {
+	return newRow(f, width)
+}
+
+func (f PrimeField) Matrix(height, width int) Matrix {
+	return newMatrix(f, height, width)
+}
+
+func (f PrimeField) Zero() Elem {
+	return f.Elem(nil)
+}
+
+func (f PrimeField) One() Elem {
+	return f.Elem([]byte{1})
+}
+
+func (f PrimeField) Size() int {
+	return f.size
+}
+
+// Modulus returns p.
+func (f PrimeField) Modulus() *big.Int {
+	return new(big.Int).Set(f.p)
+}
+
+func (f PrimeField) Point(i int) Elem {
+	return f.fromInt(big.NewInt(int64(i + 1)))
+}
+
+func (f PrimeField) MaxPoints() int {
+	if f.p.BitLen() > 63 {
+		return math.MaxInt
+	}
+
+	return int(f.p.Int64() - 1)
+}
+
+func (f PrimeField) Vandermonde(points []int, width int) Matrix {
+	return newVandermonde(f, points, width)
+}
+
+func (f PrimeField) add(dst, a, b []byte) {
+	x := f.toInt(a)
+	x.Add(x, f.toInt(b)).Mod(x, f.p)
+	x.FillBytes(dst)
+}
+
+func (f PrimeField) sub(dst, a, b []byte) {
+	x := f.toInt(a)
+	x.Sub(x, f.toInt(b)).Mod(x, f.p)
+	x.FillBytes(dst)
+}
+
+func (f PrimeField) mul(dst, a, b []byte) {
+	x := f.toInt(a)
+	x.Mul(x, f.toInt(b)).Mod(x, f.p)
+	x.FillBytes(dst)
+}
+
+func (f PrimeField) invert(dst, a []byte) {
+	x := f.toInt(a)
+	if x.ModInverse(x, f.p) == nil {
+		x.SetInt64(0)
+	}
+	x.FillBytes(dst)
+}
+
+// random reduces 16 more bytes than the size of p, so that the bias is
+// negligible.
+func (f PrimeField) random(r io.Reader) ([]byte, error) {
+	buf := make([]byte, f.size+16)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+
+	return f.Elem(buf).e, nil
+}
+
+func (f PrimeField) toInt(a []byte) *big.Int {
+	return new(big.Int).SetBytes(a)
+}
+
+func (f PrimeField) fromInt(x *big.Int) Elem {
+	x = new(big.Int).Mod(x, f.p)
+
+	return Elem{
+		Field: f,
+		e:     x.FillBytes(make([]byte, f.size)),
+	}
+}
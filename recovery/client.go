@@ -0,0 +1,81 @@
+package recovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// A Client talks to a recovery session.
+type Client struct {
+	URL        string       // The session's base URL.
+	HTTPClient *http.Client // If nil, http.DefaultClient is used.
+}
+
+// Status returns the session's status.
+func (c *Client) Status(ctx context.Context) (Status, error) {
+	return c.do(ctx, http.MethodGet, "/status", nil)
+}
+
+// Submit sends the named user's shares to the session, and returns its status
+// afterwards.
+func (c *Client) Submit(ctx context.Context, name string, shares [][]byte) (Status, error) {
+	body, err := json.Marshal(submission{name, shares})
+	if err != nil {
+		return Status{}, err
+	}
+
+	return c.do(ctx, http.MethodPost, "/shares", body)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body []byte) (st Status, err error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.URL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return st, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return st, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return st, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &e) != nil || e.Error == "" {
+			return st, errors.New("Unexpected response: " + resp.Status)
+		}
+
+		return st, &Error{resp.StatusCode, e.Error}
+	}
+
+	err = json.Unmarshal(data, &st)
+	return st, err
+}
+
+// An Error is an error returned by the session.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string { return e.Message }
@@ -0,0 +1,310 @@
+// Package recovery runs recovery ceremonies over HTTP:  a server hosts a
+// session for one predicate, holders submit their shares to it from wherever
+// they are, and the secret is recovered on the server as soon as the shares
+// submitted satisfy the predicate.
+//
+// The session doesn't authenticate holders or encrypt anything itself, so it
+// should be served over TLS, behind whatever authentication the ceremony
+// needs.  The secret is never sent over HTTP; it's returned by Session.Wait.
+package recovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Bren2010/msp"
+)
+
+// maxSets is the most minimal authorized sets that are searched to find which
+// users are still needed.
+const maxSets = 1000
+
+// Status is the state of a recovery session, as returned by GET /status.
+type Status struct {
+	Predicate string `json:"predicate"`
+
+	Present   []string   `json:"present"`          // Users who have submitted their shares.
+	Needed    [][]string `json:"needed,omitempty"` // The smallest sets of users who could submit shares to finish recovery.
+	Satisfied bool       `json:"satisfied"`        // Whether the shares present satisfy the predicate.
+	Using     []string   `json:"using,omitempty"`  // Once satisfied, the users whose shares recovery uses, from DerivePath.
+
+	Recovered bool   `json:"recovered"`
+	Error     string `json:"error,omitempty"` // Set if recovery failed.
+}
+
+// submission is the body of POST /shares.
+type submission struct {
+	Name   string   `json:"name"`
+	Shares [][]byte `json:"shares"`
+}
+
+// A Session collects shares for one predicate over HTTP.  It serves:
+//
+//	GET /status   The session's Status.
+//	POST /shares  A holder's shares, as {"name": ..., "shares": [base64, ...]}.
+//	              Responds with the new Status.
+//
+// Each holder can submit once, and only before the secret is recovered.  If
+// recovery fails, the session stays open and keeps collecting shares:
+//
+//   - Holders named in an *msp.InconsistentSharesError, as returned by
+//     RecoverSecretRobust, have their submissions dropped, so they can submit
+//     again.
+//   - Otherwise, recovery is retried leaving out each holder in turn, so one
+//     bad submission is routed around once enough others arrive.  The holder
+//     left out of a successful recovery has their submission dropped.
+//
+// Recovery only fails for good if it still fails once every holder has
+// submitted.
+type Session struct {
+	m       msp.MSP
+	users   map[string]int // Number of shares each user has.
+	recover func(msp.MSP, msp.UserDatabase) ([]byte, error)
+	mux     *http.ServeMux
+
+	mu     sync.Mutex
+	shares shareDatabase
+	secret []byte
+	err    error         // The last recovery error.
+	done   chan struct{} // Closed once recovery has succeeded or failed for good.
+}
+
+// NewSession returns a session that recovers the secret with RecoverSecret.
+func NewSession(m msp.MSP) *Session {
+	return NewSessionWithRecover(m, msp.MSP.RecoverSecret)
+}
+
+// NewSessionWithRecover returns a session that recovers the secret with the
+// given function, such as msp.MSP.RecoverSecretRobust, or a closure around
+// RecoverSecretFromEnvelopes for shares in envelopes.
+func NewSessionWithRecover(m msp.MSP, recover func(msp.MSP, msp.UserDatabase) ([]byte, error)) *Session {
+	s := &Session{
+		m:       m,
+		users:   msp.Formatted(m).Users(),
+		recover: recover,
+		mux:     http.NewServeMux(),
+		shares:  make(shareDatabase),
+		done:    make(chan struct{}),
+	}
+
+	s.mux.HandleFunc("GET /status", s.handleStatus)
+	s.mux.HandleFunc("POST /shares", s.handleShares)
+
+	return s
+}
+
+func (s *Session) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Wait blocks until the secret has been recovered, or recovery has failed for
+// good, or ctx is done.
+func (s *Session) Wait(ctx context.Context) ([]byte, error) {
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.secret, s.err
+}
+
+// Status returns the session's current status.
+func (s *Session) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.status()
+}
+
+func (s *Session) status() Status {
+	f := msp.Formatted(s.m)
+	st := Status{Predicate: f.String(), Present: []string{}, Satisfied: f.Ok(s.shares)}
+
+	for name := range s.shares {
+		st.Present = append(st.Present, name)
+	}
+	sort.Strings(st.Present)
+
+	if s.err != nil {
+		st.Error = s.err.Error()
+	}
+
+	select {
+	case <-s.done:
+		st.Recovered = s.err == nil
+	default:
+	}
+
+	if st.Satisfied {
+		_, _, _, st.Using = s.m.DerivePath(s.shares)
+		return st
+	}
+
+	// The users still needed are the smallest differences between a minimal
+	// authorized set and the users present.
+	sets, ok := f.MinimalSets(maxSets)
+	if !ok {
+		return st
+	}
+
+	// Different minimal sets can leave the same users missing.
+	seen := make(map[string]bool)
+
+	for _, set := range sets {
+		missing := []string{}
+		for _, name := range set {
+			if _, present := s.shares[name]; !present {
+				missing = append(missing, name)
+			}
+		}
+
+		if len(st.Needed) > 0 && len(missing) < len(st.Needed[0]) {
+			st.Needed, seen = nil, make(map[string]bool)
+		}
+
+		key := strings.Join(missing, "\x00")
+		if (len(st.Needed) == 0 || len(missing) == len(st.Needed[0])) && !seen[key] {
+			st.Needed, seen[key] = append(st.Needed, missing), true
+		}
+	}
+
+	sort.Slice(st.Needed, func(i, j int) bool {
+		return strings.Join(st.Needed[i], "\x00") < strings.Join(st.Needed[j], "\x00")
+	})
+
+	return st
+}
+
+func (s *Session) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Status())
+}
+
+func (s *Session) handleShares(w http.ResponseWriter, r *http.Request) {
+	var sub submission
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&sub); err != nil {
+		writeError(w, http.StatusBadRequest, errors.New("Malformed request."))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		writeError(w, http.StatusGone, errors.New("Recovery is already over."))
+		return
+	default:
+	}
+
+	if count, ok := s.users[sub.Name]; !ok {
+		writeError(w, http.StatusNotFound, errors.New("Unknown user."))
+		return
+	} else if len(sub.Shares) != count {
+		writeError(w, http.StatusBadRequest, errors.New("Wrong number of shares."))
+		return
+	} else if _, ok := s.shares[sub.Name]; ok {
+		writeError(w, http.StatusConflict, errors.New("Shares were already submitted."))
+		return
+	}
+
+	s.shares[sub.Name] = sub.Shares
+	s.tryRecover()
+
+	writeJSON(w, http.StatusOK, s.status())
+}
+
+// tryRecover recovers the secret if the shares present satisfy the predicate,
+// closing s.done if it succeeds or can never succeed.
+func (s *Session) tryRecover() {
+	f := msp.Formatted(s.m)
+	if !f.Ok(s.shares) {
+		return
+	}
+
+	sec, err := s.recover(s.m, s.shares)
+	if ise, ok := err.(*msp.InconsistentSharesError); ok {
+		for _, name := range ise.Names {
+			delete(s.shares, name)
+		}
+
+		s.err = err
+		s.tryRecover()
+		return
+	}
+
+	if err != nil {
+		present := make([]string, 0, len(s.shares))
+		for name := range s.shares {
+			present = append(present, name)
+		}
+		sort.Strings(present)
+
+		for _, name := range present {
+			others := make(shareDatabase, len(s.shares))
+			for other, shares := range s.shares {
+				if other != name {
+					others[other] = shares
+				}
+			}
+
+			if !f.Ok(others) {
+				continue
+			} else if out, otherErr := s.recover(s.m, others); otherErr == nil {
+				delete(s.shares, name)
+				sec, err = out, nil
+				break
+			}
+		}
+	}
+
+	s.err = err
+	if err == nil {
+		s.secret = sec
+		close(s.done)
+	} else if len(s.shares) == len(s.users) {
+		close(s.done)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
+
+// shareDatabase is a user database of the shares submitted so far.
+type shareDatabase map[string][][]byte
+
+func (sd shareDatabase) ValidUser(name string) bool {
+	_, ok := sd[name]
+	return ok
+}
+
+func (sd shareDatabase) CanGetShare(name string) bool {
+	_, ok := sd[name]
+	return ok
+}
+
+func (sd shareDatabase) GetShare(name string) ([][]byte, error) {
+	out, ok := sd[name]
+	if !ok {
+		return nil, errors.New("Not found!")
+	}
+
+	return out, nil
+}
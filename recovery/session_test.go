@@ -0,0 +1,222 @@
+package recovery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Bren2010/msp"
+)
+
+type anyone struct{}
+
+func (anyone) ValidUser(name string) bool   { return true }
+func (anyone) CanGetShare(name string) bool { return false }
+func (anyone) GetShare(name string) ([][]byte, error) {
+	return nil, errors.New("Not implemented.")
+}
+
+func TestSession(t *testing.T) {
+	sec := []byte("attack at dawn")
+	predicate, _ := msp.StringToMSP("(2, (1, Alice, Bob), (2, Alice, Dave), Carl)")
+
+	shares, err := predicate.DistributeShares(sec, anyone{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := NewSession(predicate)
+	server := httptest.NewServer(session)
+	defer server.Close()
+
+	ctx := context.Background()
+	client := &Client{URL: server.URL}
+
+	st, err := client.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	} else if st.Satisfied || len(st.Present) != 0 || !reflect.DeepEqual(st.Needed, [][]string{{"Alice", "Carl"}, {"Alice", "Dave"}, {"Bob", "Carl"}}) {
+		t.Fatalf("Initial status was wrong: %+v", st)
+	}
+
+	st, err = client.Submit(ctx, "Alice", shares["Alice"])
+	if err != nil {
+		t.Fatal(err)
+	} else if st.Satisfied || !reflect.DeepEqual(st.Needed, [][]string{{"Carl"}, {"Dave"}}) {
+		t.Fatalf("Status after Alice was wrong: %+v", st)
+	}
+
+	// Bad submissions are rejected.
+	tests := []struct {
+		name   string
+		shares [][]byte
+		code   int
+	}{
+		{"Alice", shares["Alice"], http.StatusConflict},
+		{"Eve", shares["Bob"], http.StatusNotFound},
+		{"Carl", shares["Alice"], http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		var e *Error
+		if _, err := client.Submit(ctx, test.name, test.shares); !errors.As(err, &e) || e.StatusCode != test.code {
+			t.Fatalf("%v: wanted status %v, got %v", test.name, test.code, err)
+		}
+	}
+
+	// The secret isn't recovered yet.
+	shortCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := session.Wait(shortCtx); err != context.DeadlineExceeded {
+		t.Fatalf("Secret was recovered early: %v", err)
+	}
+
+	st, err = client.Submit(ctx, "Dave", shares["Dave"])
+	if err != nil {
+		t.Fatal(err)
+	} else if !st.Satisfied || !st.Recovered || !reflect.DeepEqual(st.Using, []string{"Alice", "Dave"}) {
+		t.Fatalf("Status after Dave was wrong: %+v", st)
+	}
+
+	out, err := session.Wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(sec, out) {
+		t.Fatalf("Secrets derived differed:  %x %x", sec, out)
+	}
+
+	var e *Error
+	if _, err := client.Submit(ctx, "Carl", shares["Carl"]); !errors.As(err, &e) || e.StatusCode != http.StatusGone {
+		t.Fatalf("Submission after recovery wasn't rejected: %v", err)
+	}
+}
+
+func TestSessionRecoveryError(t *testing.T) {
+	predicate, _ := msp.StringToMSP("(2, Alice, Bob)")
+
+	errRecover := errors.New("Shares are inconsistent.")
+	session := NewSessionWithRecover(predicate, func(msp.MSP, msp.UserDatabase) ([]byte, error) {
+		return nil, errRecover
+	})
+	server := httptest.NewServer(session)
+	defer server.Close()
+
+	ctx := context.Background()
+	client := &Client{URL: server.URL}
+
+	client.Submit(ctx, "Alice", [][]byte{[]byte("a")})
+	st, err := client.Submit(ctx, "Bob", [][]byte{[]byte("b")})
+	if err != nil {
+		t.Fatal(err)
+	} else if st.Recovered || st.Error != errRecover.Error() {
+		t.Fatalf("Failed recovery wasn't reported: %+v", st)
+	}
+
+	if _, err := session.Wait(ctx); err != errRecover {
+		t.Fatalf("Wait didn't return the recovery error: %v", err)
+	}
+}
+
+func TestSessionNeededUnique(t *testing.T) {
+	predicate, _ := msp.StringToMSP("(2, (1, Alice, Bob), Carl)")
+
+	shares, err := predicate.DistributeShares([]byte("attack at dawn"), anyone{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := NewSession(predicate)
+	server := httptest.NewServer(session)
+	defer server.Close()
+
+	ctx := context.Background()
+	client := &Client{URL: server.URL}
+
+	client.Submit(ctx, "Alice", shares["Alice"])
+	st, err := client.Submit(ctx, "Bob", shares["Bob"])
+	if err != nil {
+		t.Fatal(err)
+	} else if st.Satisfied || !reflect.DeepEqual(st.Needed, [][]string{{"Carl"}}) {
+		t.Fatalf("Status after Alice and Bob was wrong: %+v", st)
+	}
+}
+
+func TestSessionBadSubmission(t *testing.T) {
+	sec := []byte("attack at dawn")
+	predicate, _ := msp.StringToMSP("(2, Alice, Bob, Carl)")
+
+	shares, tag, err := predicate.DistributeSharesTagged(sec, anyone{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := NewSessionWithRecover(predicate, func(m msp.MSP, db msp.UserDatabase) ([]byte, error) {
+		return m.RecoverSecretTagged(db, tag)
+	})
+	server := httptest.NewServer(session)
+	defer server.Close()
+
+	ctx := context.Background()
+	client := &Client{URL: server.URL}
+
+	// Bob's garbage and Alice's share satisfy the predicate, but don't recover
+	// the secret.  The session stays open.
+	garbage := [][]byte{bytes.Repeat([]byte{0x5a}, len(shares["Bob"][0]))}
+	client.Submit(ctx, "Bob", garbage)
+
+	st, err := client.Submit(ctx, "Alice", shares["Alice"])
+	if err != nil {
+		t.Fatal(err)
+	} else if st.Recovered || st.Error != msp.ErrIntegrity.Error() {
+		t.Fatalf("Failed recovery wasn't reported: %+v", st)
+	}
+
+	// Once Carl submits, recovery succeeds without Bob, whose submission is
+	// dropped.
+	st, err = client.Submit(ctx, "Carl", shares["Carl"])
+	if err != nil {
+		t.Fatal(err)
+	} else if !st.Recovered || st.Error != "" || !reflect.DeepEqual(st.Present, []string{"Alice", "Carl"}) {
+		t.Fatalf("Recovery without the bad submission failed: %+v", st)
+	}
+
+	if out, err := session.Wait(ctx); err != nil || !bytes.Equal(out, sec) {
+		t.Fatalf("Wrong secret: %q, %v", out, err)
+	}
+}
+
+func TestSessionInconsistentShares(t *testing.T) {
+	predicate, _ := msp.StringToMSP("(2, Alice, Bob)")
+
+	// Recovery names Alice as long as she's submitted the wrong share.
+	session := NewSessionWithRecover(predicate, func(m msp.MSP, db msp.UserDatabase) ([]byte, error) {
+		if shares, _ := db.GetShare("Alice"); string(shares[0]) != "a" {
+			return nil, &msp.InconsistentSharesError{Names: []string{"Alice"}}
+		}
+		return []byte("secret"), nil
+	})
+	server := httptest.NewServer(session)
+	defer server.Close()
+
+	ctx := context.Background()
+	client := &Client{URL: server.URL}
+
+	client.Submit(ctx, "Alice", [][]byte{[]byte("x")})
+	st, err := client.Submit(ctx, "Bob", [][]byte{[]byte("b")})
+	if err != nil {
+		t.Fatal(err)
+	} else if st.Recovered || !reflect.DeepEqual(st.Present, []string{"Bob"}) || st.Error == "" {
+		t.Fatalf("Inconsistent shares weren't dropped: %+v", st)
+	}
+
+	// Alice can submit again.
+	if st, err := client.Submit(ctx, "Alice", [][]byte{[]byte("a")}); err != nil {
+		t.Fatal(err)
+	} else if !st.Recovered {
+		t.Fatalf("Recovery failed after resubmission: %+v", st)
+	}
+}
@@ -0,0 +1,74 @@
+package msp
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+// Refresh returns new random shares of zero under the same predicate, for a
+// secret of the given size split by DistributeShares.  Each user adds their
+// deltas to their shares with ApplyRefresh.  The refreshed shares recover the
+// same secret, but can't be combined with shares from before the refresh, so
+// shares that leaked before it become useless.  The secret is never assembled.
+//
+// Every holder has to apply their deltas and then delete their old shares.
+func (m MSP) Refresh(size int, db UserDatabase) (map[string][][]byte, error) {
+	return m.RefreshInField(nil, rand.Reader, size, db)
+}
+
+// RefreshInField is the same as Refresh, for a secret split over the given
+// field by DistributeSharesInField.  If field is nil, it's chosen by size as in
+// DistributeShares.
+func (m MSP) RefreshInField(field Field, random io.Reader, size int, db UserDatabase) (map[string][][]byte, error) {
+	if size == 0 {
+		return nil, errors.New("Secret can't be empty.")
+	}
+
+	return m.distribute(make([]byte, size), db, fieldSplitter{random, field})
+}
+
+// ApplyRefresh adds a user's deltas from Refresh to their shares, returning
+// their new shares.  field is the field the secret was split over, or nil if
+// it was chosen by the secret's size.
+func ApplyRefresh(field Field, shares, deltas [][]byte) ([][]byte, error) {
+	if len(shares) != len(deltas) {
+		return nil, errors.New("Wrong number of deltas.")
+	}
+
+	out := make([][]byte, len(shares))
+	for i := range shares {
+		sum, err := addShares(field, shares[i], deltas[i])
+		if err != nil {
+			return nil, err
+		}
+		out[i] = sum
+	}
+
+	return out, nil
+}
+
+// addShares adds two shares element-wise over the field, or the field chosen by
+// their size if it's nil.
+func addShares(field Field, a, b []byte) ([]byte, error) {
+	if len(a) != len(b) {
+		return nil, errors.New("Shares are different sizes!")
+	}
+
+	field, err := fieldOrDefault(field, len(a))
+	if err != nil {
+		return nil, err
+	} else if err := checkElems(field, a); err != nil {
+		return nil, err
+	} else if err := checkElems(field, b); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(a))
+	for k := 0; k < len(a); k += field.Size() {
+		x, y := field.Elem(a[k:k+field.Size()]), field.Elem(b[k:k+field.Size()])
+		out = append(out, x.Add(y).e...)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,98 @@
+package msp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestRefresh(t *testing.T) {
+	db := &Database{"Alice": nil, "Bob": nil, "Carl": nil, "Dave": nil}
+	predicate, _ := StringToMSP("(2, (1, Alice, Bob), (2, Alice, Dave), Carl)")
+
+	tests := []struct {
+		field Field
+		size  int
+	}{
+		{nil, 7},
+		{nil, 16},
+		{nil, 32},
+		{P256Field, 64},
+	}
+
+	for _, test := range tests {
+		sec := make([]byte, test.size)
+		rand.Read(sec)
+		if test.field != nil {
+			sec = P256Field.Elem(sec[:32]).Bytes()
+			sec = append(sec, sec...)
+		}
+
+		shares, err := predicate.DistributeSharesInField(test.field, rand.Reader, sec, db)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		deltas, err := predicate.RefreshInField(test.field, rand.Reader, test.size, db)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		refreshed := Database{}
+		for name := range shares {
+			if refreshed[name], err = ApplyRefresh(test.field, shares[name], deltas[name]); err != nil {
+				t.Fatal(err)
+			} else if bytes.Equal(refreshed[name][0], shares[name][0]) {
+				t.Fatalf("Size %v: %v's share didn't change.", test.size, name)
+			}
+		}
+
+		out, err := predicate.RecoverSecretInField(test.field, &refreshed)
+		if err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(sec, out) {
+			t.Fatalf("Size %v: Secrets derived differed:  %x %x", test.size, sec, out)
+		}
+
+		// Old shares don't combine with new ones.
+		mixed := Database{"Alice": shares["Alice"], "Carl": refreshed["Carl"]}
+		if out, err := predicate.RecoverSecretInField(test.field, &mixed); err == nil && bytes.Equal(sec, out) {
+			t.Fatalf("Size %v: Old and new shares combined!", test.size)
+		}
+	}
+}
+
+func TestRefreshTagged(t *testing.T) {
+	db := &Database{"Alice": nil, "Bob": nil, "Carl": nil}
+	predicate, _ := StringToMSP("(2, Alice, Bob, Carl)")
+	sec := []byte("attack at dawn")
+
+	shares, tag, err := predicate.DistributeSharesTagged(sec, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deltas, err := predicate.Refresh(len(sec), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refreshed := Database{}
+	for name := range shares {
+		refreshed[name], _ = ApplyRefresh(nil, shares[name], deltas[name])
+	}
+
+	if out, err := predicate.RecoverSecretTagged(&refreshed, tag); err != nil || !bytes.Equal(sec, out) {
+		t.Fatalf("Refreshed shares didn't recover the secret: %v", err)
+	}
+
+	// With a tag, a mix of old and new shares is detected.
+	mixed := Database{"Alice": shares["Alice"], "Bob": refreshed["Bob"]}
+	if _, err := predicate.RecoverSecretTagged(&mixed, tag); err != ErrIntegrity {
+		t.Fatalf("Mixed shares weren't detected: %v", err)
+	}
+
+	if _, err := ApplyRefresh(nil, shares["Alice"], deltas["Bob"][:0]); err == nil {
+		t.Fatalf("Wrong number of deltas was accepted!")
+	}
+}
@@ -0,0 +1,184 @@
+package msp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Resharing moves a secret from one predicate to another without assembling
+// it.  The old holders taking part agree on who they are, which fixes the
+// shares that DerivePath would recover the secret from and their recovery
+// coefficients:  the secret is the sum of each coefficient times its share.
+// Each of those old holders splits each of their shares that's used under the
+// new predicate, with Reshare, and sends every new holder their sub-shares.
+// Each new holder weights the sub-shares they get by the recovery coefficients
+// and adds them up, with CombineSubShares, which gives them shares of the
+// secret under the new predicate.
+//
+// Afterwards, the old holders should delete their shares.
+
+// SubShares are what one old holder sends to one new holder while resharing.
+type SubShares struct {
+	From   string           // The old holder.
+	Shares map[int][][]byte // The new holder's shares of each of the old holder's shares, by index.
+}
+
+// RecoveryCoefficients returns the coefficients that RecoverSecret would use to
+// recover a secret split over field from the users available in db:  the
+// secret is the sum of coeffs[name][i] times the ith share of each user.
+// Nested threshold gates are accounted for, so the coefficients are products of
+// the recovery vectors of each gate along the way.
+func (m MSP) RecoveryCoefficients(field Field, db UserDatabase) (coeffs map[string]map[int]Elem, err error) {
+	coeffs = make(map[string]map[int]Elem)
+	if err := m.recoveryCoefficients(field, db, field.One(), coeffs); err != nil {
+		return nil, err
+	}
+
+	return coeffs, nil
+}
+
+func (m MSP) recoveryCoefficients(field Field, db UserDatabase, scale Elem, coeffs map[string]map[int]Elem) error {
+	ok, _, locs, _ := m.DerivePath(db)
+	if !ok {
+		return errNotEnoughShares
+	} else if err := m.validate(field.MaxPoints()); err != nil {
+		return err
+	}
+
+	r, ok := field.Vandermonde(locs, m.Min).Recovery()
+	if !ok {
+		return errors.New("Unable to find a reconstruction vector!")
+	}
+
+	for i, loc := range locs {
+		c := scale.Mul(r.r[i])
+
+		switch cond := m.Conds[loc].(type) {
+		case Name:
+			if coeffs[cond.string] == nil {
+				coeffs[cond.string] = make(map[int]Elem)
+			}
+			coeffs[cond.string][cond.index] = c
+
+		case Formatted:
+			if err := MSP(cond).recoveryCoefficients(field, db, c, coeffs); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Reshare is run by each old holder taking part in resharing, who are the
+// users available in participants.  It splits each of the named holder's shares
+// that are needed under the next predicate, and returns the sub-shares to send
+// to each new holder in db.  field is the field the secret was split over, or
+// nil if it was chosen by the secret's size.
+func (m MSP) Reshare(field Field, random io.Reader, participants UserDatabase, name string, shares [][]byte, next MSP, db UserDatabase) (map[string]SubShares, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("No shares to reshare.")
+	}
+
+	field, err := fieldOrDefault(field, len(shares[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	coeffs, err := m.RecoveryCoefficients(field, participants)
+	if err != nil {
+		return nil, err
+	} else if _, ok := coeffs[name]; !ok {
+		return nil, errors.New("User's shares aren't needed.")
+	}
+
+	out := make(map[string]SubShares)
+	for index := range coeffs[name] {
+		if index >= len(shares) {
+			return nil, errors.New("Predicate / database mismatch!")
+		}
+
+		sub, err := next.DistributeSharesInField(field, random, shares[index], db)
+		if err != nil {
+			return nil, err
+		}
+
+		for newName, newShares := range sub {
+			if _, ok := out[newName]; !ok {
+				out[newName] = SubShares{name, make(map[int][][]byte)}
+			}
+			out[newName].Shares[index] = newShares
+		}
+	}
+
+	return out, nil
+}
+
+// CombineSubShares is run by each new holder once they have sub-shares from
+// every old holder taking part, who are the users available in participants.
+// It returns the new holder's shares under the new predicate.
+func (m MSP) CombineSubShares(field Field, participants UserDatabase, subs []SubShares) ([][]byte, error) {
+	bySender := make(map[string]map[int][][]byte)
+	count, size := -1, 0
+
+	for _, sub := range subs {
+		if _, ok := bySender[sub.From]; ok {
+			return nil, fmt.Errorf("Sub-shares from %v were given twice.", sub.From)
+		}
+		bySender[sub.From] = sub.Shares
+
+		for _, shares := range sub.Shares {
+			if count == -1 {
+				count = len(shares)
+				if count > 0 {
+					size = len(shares[0])
+				}
+			} else if len(shares) != count {
+				return nil, errors.New("Sub-shares are for different predicates.")
+			}
+		}
+	}
+	if count < 1 {
+		return nil, errors.New("No sub-shares to combine.")
+	}
+
+	field, err := fieldOrDefault(field, size)
+	if err != nil {
+		return nil, err
+	}
+
+	coeffs, err := m.RecoveryCoefficients(field, participants)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([][]byte, count)
+	for i := range out {
+		out[i] = make([]byte, size)
+	}
+
+	for name, userCoeffs := range coeffs {
+		for index, c := range userCoeffs {
+			shares, ok := bySender[name][index]
+			if !ok {
+				return nil, fmt.Errorf("Sub-shares of share %v of %v are missing.", index, name)
+			}
+
+			for i, share := range shares {
+				if len(share) != size {
+					return nil, errors.New("Shares are different sizes!")
+				} else if err := checkElems(field, share); err != nil {
+					return nil, err
+				}
+
+				for k := 0; k < size; k += field.Size() {
+					sum := field.Elem(out[i][k : k+field.Size()]).Add(c.Mul(field.Elem(share[k : k+field.Size()])))
+					copy(out[i][k:], sum.e)
+				}
+			}
+		}
+	}
+
+	return out, nil
+}
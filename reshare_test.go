@@ -0,0 +1,105 @@
+package msp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestRecoveryCoefficients(t *testing.T) {
+	db := &Database{"Alice": nil, "Bob": nil, "Carl": nil, "Dave": nil}
+	predicate, _ := StringToMSP("(2, (1, Alice, Bob), (2, Alice, Dave), Carl)")
+	sec := make([]byte, 16)
+	rand.Read(sec)
+
+	shares, err := predicate.DistributeShares(sec, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	present := Database{"Alice": shares["Alice"], "Dave": shares["Dave"]}
+	coeffs, err := predicate.RecoveryCoefficients(Fields[16], &present)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(coeffs["Alice"]) != 2 || len(coeffs["Dave"]) != 1 {
+		t.Fatalf("Wrong shares were used: %v", coeffs)
+	}
+
+	out := Fields[16].Zero()
+	for name, userCoeffs := range coeffs {
+		for index, c := range userCoeffs {
+			out.AddM(c.Mul(Fields[16].Elem(shares[name][index])))
+		}
+	}
+
+	if !bytes.Equal(sec, out.e) {
+		t.Fatalf("Secrets derived differed:  %x %x", sec, out.e)
+	}
+}
+
+func TestReshare(t *testing.T) {
+	oldDb := &Database{"Alice": nil, "Bob": nil, "Carl": nil, "Dave": nil}
+	newDb := &Database{"Carl": nil, "Eve": nil, "Frank": nil}
+
+	old, _ := StringToMSP("(2, (1, Alice, Bob), (2, Alice, Dave), Carl)")
+	next, _ := StringToMSP("(2, (1, Eve, Frank), Carl, Carl)")
+
+	for _, size := range []int{7, 16, 32} {
+		sec := make([]byte, size)
+		rand.Read(sec)
+
+		shares, err := old.DistributeShares(sec, oldDb)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Alice and Dave take part, and Bob doesn't.
+		participants := &Database{"Alice": nil, "Dave": nil}
+
+		if _, err := old.Reshare(nil, rand.Reader, participants, "Carl", shares["Carl"], next, newDb); err == nil {
+			t.Fatalf("Size %v: Carl's shares were reshared, but aren't needed.", size)
+		}
+
+		received := make(map[string][]SubShares)
+		for _, name := range []string{"Alice", "Dave"} {
+			subs, err := old.Reshare(nil, rand.Reader, participants, name, shares[name], next, newDb)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for newName, sub := range subs {
+				received[newName] = append(received[newName], sub)
+			}
+		}
+
+		newShares := Database{}
+		for newName, subs := range received {
+			if newShares[newName], err = old.CombineSubShares(nil, participants, subs); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if len(newShares["Carl"]) != 2 {
+			t.Fatalf("Size %v: Carl should have 2 new shares, not %v.", size, len(newShares["Carl"]))
+		}
+
+		for _, users := range [][]string{{"Carl"}, {"Eve", "Carl"}, {"Frank", "Carl"}} {
+			db := Database{}
+			for _, name := range users {
+				db[name] = newShares[name]
+			}
+
+			out, err := next.RecoverSecret(&db)
+			if err != nil {
+				t.Fatal(err)
+			} else if !bytes.Equal(sec, out) {
+				t.Fatalf("Size %v: Secrets derived differed:  %x %x", size, sec, out)
+			}
+		}
+
+		// Everybody's sub-shares are needed.
+		if _, err := old.CombineSubShares(nil, participants, received["Eve"][:1]); err == nil {
+			t.Fatalf("Size %v: Missing sub-shares weren't noticed!", size)
+		}
+	}
+}
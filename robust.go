@@ -0,0 +1,294 @@
+package msp
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// InconsistentSharesError is returned by RecoverSecretRobust when some users'
+// shares don't agree with the others.
+type InconsistentSharesError struct {
+	Names []string // Users with inconsistent shares, sorted.
+}
+
+func (e *InconsistentSharesError) Error() string {
+	return "Inconsistent shares from: " + strings.Join(e.Names, ", ")
+}
+
+// ErrUndecodable is returned by RecoverSecretRobust when a threshold gate's
+// shares disagree, but there aren't enough of them to tell which are wrong.
+var ErrUndecodable = errors.New("Shares are inconsistent, and there aren't enough to tell which are wrong.")
+
+// robustShare is the share of one condition of a threshold gate, along with the
+// users it came from.
+type robustShare struct {
+	loc   int
+	share []byte
+	names []string
+}
+
+// RecoverSecretRobust recovers the secret like RecoverSecret, but detects
+// corrupted shares.  Instead of the fewest shares possible, it fetches every
+// share it can and, at each threshold gate with more shares than its threshold,
+// looks for the polynomial that the most shares agree with.  If a majority of
+// the redundant shares agree, the users whose shares don't are returned in an
+// *InconsistentSharesError.  If a nested threshold gate's recovered share is
+// inconsistent, or its own shares can't be decoded, every user who contributed
+// to it is named.
+//
+// If too many shares are wrong to tell which ones, ErrUndecodable is returned
+// instead.  Once the named users are removed from the database, RecoverSecret
+// will recover the right secret.
+func (m MSP) RecoverSecretRobust(db UserDatabase) ([]byte, error) {
+	cache := make(map[string][][]byte, 0)
+
+	sec, _, bad, err := m.recoverRobust(db, cache)
+	if len(bad) > 0 {
+		sort.Strings(bad)
+
+		uniq := bad[:1]
+		for _, name := range bad[1:] {
+			if name != uniq[len(uniq)-1] {
+				uniq = append(uniq, name)
+			}
+		}
+
+		return nil, &InconsistentSharesError{uniq}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return sec, nil
+}
+
+// recoverRobust returns the secret of the MSP's top-level threshold gate, the
+// users whose shares it was recovered from, and the users whose shares were
+// inconsistent.  If the gate can't be decoded, names is every user whose shares
+// were available.
+func (m MSP) recoverRobust(db UserDatabase, cache map[string][][]byte) (sec []byte, names, bad []string, err error) {
+	avail := []robustShare{}
+
+	// Nested threshold gates that couldn't be decoded are left out, and their
+	// users are only named if this gate can be decoded without them.
+	undecodable, suspects := false, []string{}
+
+	for i, cond := range m.Conds {
+		switch cond := cond.(type) {
+		case Name:
+			if !db.CanGetShare(cond.string) {
+				continue
+			}
+
+			if _, cached := cache[cond.string]; !cached {
+				out, err := db.GetShare(cond.string)
+				if err != nil {
+					return nil, nil, bad, err
+				}
+
+				cache[cond.string] = out
+			}
+
+			if len(cache[cond.string]) <= cond.index {
+				return nil, nil, bad, errors.New("Predicate / database mismatch!")
+			}
+
+			avail = append(avail, robustShare{i, cache[cond.string][cond.index], []string{cond.string}})
+
+		case Formatted:
+			share, subNames, subBad, err := MSP(cond).recoverRobust(db, cache)
+			bad = append(bad, subBad...)
+
+			if err == errNotEnoughShares {
+				continue
+			} else if err == ErrUndecodable {
+				undecodable, suspects = true, append(suspects, subNames...)
+				continue
+			} else if err != nil {
+				return nil, nil, bad, err
+			}
+
+			avail = append(avail, robustShare{i, share, subNames})
+		}
+	}
+
+	if len(avail) < m.Min && undecodable {
+		for _, rs := range avail {
+			names = append(names, rs.names...)
+		}
+
+		return nil, append(names, suspects...), bad, ErrUndecodable
+	} else if len(avail) < m.Min {
+		return nil, nil, bad, errNotEnoughShares
+	}
+
+	size := len(avail[0].share)
+	for _, rs := range avail {
+		if len(rs.share) != size {
+			return nil, nil, bad, errors.New("Shares are different sizes!")
+		}
+	}
+
+	field, err := fieldFor(size)
+	if err != nil {
+		return nil, nil, bad, err
+	} else if err := m.validate(field.MaxPoints()); err != nil {
+		return nil, nil, bad, err
+	}
+
+	agree := m.decode(field, avail)
+	if agree == nil {
+		for _, rs := range avail {
+			names = append(names, rs.names...)
+		}
+
+		return nil, append(names, suspects...), bad, ErrUndecodable
+	}
+	bad = append(bad, suspects...)
+
+	locs, shares := []int{}, [][]byte{}
+	for i, rs := range avail {
+		if !agree[i] {
+			bad = append(bad, rs.names...)
+			continue
+		}
+
+		names = append(names, rs.names...)
+		if len(locs) < m.Min {
+			locs, shares = append(locs, rs.loc), append(shares, rs.share)
+		}
+	}
+
+	sec, err = fieldCombiner{field}.Combine(m, locs, shares)
+	return sec, names, bad, err
+}
+
+// decode finds the polynomial that the most available shares lie on, and returns
+// which shares lie on it.  With n shares and a threshold of k, the polynomial is
+// only unique if at least (n+k)/2 shares agree; otherwise decode returns nil.
+//
+// Each block of the shares is decoded on its own with the Berlekamp-Welch
+// algorithm, and a share agrees if all of its blocks lie on the decoded
+// polynomials.
+func (m MSP) decode(field Field, avail []robustShare) []bool {
+	n, k := len(avail), m.Min
+
+	locs := make([]int, n)
+	for i, rs := range avail {
+		locs[i] = rs.loc
+	}
+
+	agree := make([]bool, n)
+	for i := range agree {
+		agree[i] = true
+	}
+
+	ys := make([]Elem, n)
+	for off := 0; off < len(avail[0].share); off += field.Size() {
+		for i, rs := range avail {
+			ys[i] = field.Elem(rs.share[off : off+field.Size()])
+		}
+
+		vals, ok := welch(field, locs, ys, k)
+		if !ok {
+			return nil
+		}
+
+		for i := range agree {
+			agree[i] = agree[i] && vals.r[i].Sub(ys[i]).isZero() == 1
+		}
+	}
+
+	if 2*count(agree) < n+k {
+		return nil
+	}
+
+	return agree
+}
+
+// welch decodes the values ys at the conditions locs to a polynomial of degree
+// less than k, and returns the polynomial's value at each condition.  It fails
+// if no polynomial lies on all but (n-k)/2 of the values.
+//
+// The polynomial is P = Q/E, where the error locator E is monic of degree e and
+// Q(x_i) = y_i E(x_i) for every i.  These are linear in the coefficients of Q and
+// E, and with 2e+k of them the solution is unique if exactly e of those values
+// are wrong.  Trying each e in turn, the number of wrong values among the first
+// 2e+k grows by at most two each step, so one of them has exactly e.
+func welch(field Field, locs []int, ys []Elem, k int) (Row, bool) {
+	n := len(locs)
+
+	for e := 0; 2*e+k <= n; e++ {
+		w := 2*e + k
+		V := field.Vandermonde(locs[:w], e+k)
+
+		// The jth row of A is the jth unknown's coefficient in each equation.  The
+		// unknowns are the e+k coefficients of Q, then the low e coefficients of E.
+		A, target := field.Matrix(w, w), field.Row(w)
+		for i := 0; i < w; i++ {
+			for j := 0; j < e+k; j++ {
+				A.m[j].r[i] = V.m[i].r[j]
+			}
+			for j := 0; j < e; j++ {
+				A.m[e+k+j].r[i] = field.Zero().Sub(ys[i].Mul(V.m[i].r[j]))
+			}
+			target.r[i] = ys[i].Mul(V.m[i].r[e])
+		}
+
+		sol, ok := A.Solve(target)
+		if !ok {
+			continue
+		}
+
+		p, ok := divide(field, sol.r[:e+k], append(append([]Elem{}, sol.r[e+k:]...), field.One()), k)
+		if !ok {
+			continue
+		}
+
+		// Any polynomial this close to the values is the only one.
+		vals, wrong := field.Vandermonde(locs, k).Mul(p), 0
+		for i := range ys {
+			wrong += 1 - vals.r[i].Sub(ys[i]).isZero()
+		}
+
+		if 2*wrong <= n-k {
+			return vals, true
+		}
+	}
+
+	return Row{}, false
+}
+
+// divide returns the quotient of the polynomials q and monic e, which has k
+// coefficients, or false if there's a remainder.
+func divide(field Field, q, e []Elem, k int) (Row, bool) {
+	rem := make([]Elem, len(q))
+	for i := range q {
+		rem[i] = q[i].Dup()
+	}
+
+	deg, p := len(e)-1, field.Row(k)
+	for i := k - 1; i >= 0; i-- {
+		p.r[i] = rem[i+deg].Dup()
+		for j := 0; j <= deg; j++ {
+			rem[i+j] = rem[i+j].Sub(p.r[i].Mul(e[j]))
+		}
+	}
+
+	for _, r := range rem[:deg] {
+		if r.isZero() == 0 {
+			return Row{}, false
+		}
+	}
+
+	return p, true
+}
+
+func count(bs []bool) (n int) {
+	for _, b := range bs {
+		if b {
+			n++
+		}
+	}
+	return
+}
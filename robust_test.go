@@ -0,0 +1,204 @@
+package msp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRobust(t *testing.T) {
+	db := &Database{
+		"Alice": [][]byte{},
+		"Bob":   [][]byte{},
+		"Carl":  [][]byte{},
+		"Dave":  [][]byte{},
+		"Eve":   [][]byte{},
+		"Fred":  [][]byte{},
+		"Gary":  [][]byte{},
+	}
+
+	predicate, _ := StringToMSP("(2, Alice, Bob, Carl, Dave, Eve, Fred, Gary)")
+
+	for _, size := range []int{16, 20} {
+		sec := make([]byte, size)
+		rand.Read(sec)
+
+		shares, err := predicate.DistributeShares(sec, db)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Honest shares recover normally.
+		sharesDb := Database(shares)
+		out, err := predicate.RecoverSecretRobust(&sharesDb)
+		if err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(sec, out) {
+			t.Fatalf("Secrets derived differed:  %x %x", sec, out)
+		}
+
+		// Corrupt Alice's and Carl's shares.  The other five still agree.
+		for _, name := range []string{"Alice", "Carl"} {
+			bad := append([]byte{}, shares[name][0]...)
+			bad[size-1] ^= 1
+			sharesDb[name] = [][]byte{bad}
+		}
+
+		_, err = predicate.RecoverSecretRobust(&sharesDb)
+		if ise, ok := err.(*InconsistentSharesError); !ok || !reflect.DeepEqual(ise.Names, []string{"Alice", "Carl"}) {
+			t.Fatalf("Wrong cheaters found: %v", err)
+		}
+
+		// Without the cheaters, the secret is recovered.
+		delete(sharesDb, "Alice")
+		delete(sharesDb, "Carl")
+
+		out, err = predicate.RecoverSecretRobust(&sharesDb)
+		if err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(sec, out) {
+			t.Fatalf("Secrets derived differed:  %x %x", sec, out)
+		}
+	}
+}
+
+func TestRobustNested(t *testing.T) {
+	db := &Database{
+		"Alice": [][]byte{},
+		"Bob":   [][]byte{},
+		"Carl":  [][]byte{},
+		"Dave":  [][]byte{},
+	}
+
+	predicate, _ := StringToMSP("(2, (2, Alice, Bob, Carl, Dave), Carl, Dave)")
+
+	sec := make([]byte, 16)
+	rand.Read(sec)
+
+	shares, err := predicate.DistributeShares(sec, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Bob's share is only used in the nested gate, where it's outvoted.
+	sharesDb := Database(shares)
+	bad := append([]byte{}, shares["Bob"][0]...)
+	bad[0] ^= 1
+	sharesDb["Bob"] = [][]byte{bad}
+
+	_, err = predicate.RecoverSecretRobust(&sharesDb)
+	if ise, ok := err.(*InconsistentSharesError); !ok || !reflect.DeepEqual(ise.Names, []string{"Bob"}) {
+		t.Fatalf("Wrong cheaters found: %v", err)
+	}
+
+	// With exactly the threshold, cheating can't be detected.
+	small := Database{"Carl": shares["Carl"], "Dave": shares["Dave"]}
+	if out, err := predicate.RecoverSecretRobust(&small); err != nil || !bytes.Equal(out, sec) {
+		t.Fatalf("Recovery with exactly the threshold failed: %v", err)
+	}
+
+	// With three shares in a 2-of-3 gate and one wrong, there's no majority to
+	// decode with.
+	flat, _ := StringToMSP("(2, Alice, Bob, Carl)")
+
+	shares, err = flat.DistributeShares(sec, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sharesDb = Database(shares)
+	sharesDb["Bob"] = [][]byte{bad}
+
+	if _, err := flat.RecoverSecretRobust(&sharesDb); err != ErrUndecodable {
+		t.Fatalf("Undecodable shares weren't rejected: %v", err)
+	}
+}
+
+func TestRobustUndecodableGate(t *testing.T) {
+	db := &Database{
+		"Alice": [][]byte{},
+		"Bob":   [][]byte{},
+		"Carl":  [][]byte{},
+		"Dave":  [][]byte{},
+		"Eve":   [][]byte{},
+	}
+
+	predicate, _ := StringToMSP("(2, (2, Alice, Bob, Carl), Dave, Eve)")
+
+	sec := make([]byte, 16)
+	rand.Read(sec)
+
+	shares, err := predicate.DistributeShares(sec, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// With one of three shares wrong, the nested gate can't tell which.  It's
+	// left out, and its users are named once the rest of the predicate decodes.
+	sharesDb := Database(shares)
+	bad := append([]byte{}, shares["Bob"][0]...)
+	bad[0] ^= 1
+	sharesDb["Bob"] = [][]byte{bad}
+
+	_, err = predicate.RecoverSecretRobust(&sharesDb)
+	if ise, ok := err.(*InconsistentSharesError); !ok || !reflect.DeepEqual(ise.Names, []string{"Alice", "Bob", "Carl"}) {
+		t.Fatalf("Wrong cheaters found: %v", err)
+	}
+
+	rest := Database{"Dave": shares["Dave"], "Eve": shares["Eve"]}
+	if out, err := predicate.RecoverSecretRobust(&rest); err != nil || !bytes.Equal(out, sec) {
+		t.Fatalf("Recovery without the nested gate failed: %v", err)
+	}
+
+	// Without enough other shares, nobody is named.
+	sharesDb = Database{"Alice": shares["Alice"], "Bob": [][]byte{bad}, "Carl": shares["Carl"], "Dave": shares["Dave"]}
+
+	if _, err := predicate.RecoverSecretRobust(&sharesDb); err != ErrUndecodable {
+		t.Fatalf("Undecodable shares weren't rejected: %v", err)
+	}
+}
+
+func TestRobustLarge(t *testing.T) {
+	db, names := Database{}, []string{}
+	for i := 0; i < 60; i++ {
+		name := fmt.Sprintf("User%02d", i)
+		db[name], names = [][]byte{}, append(names, name)
+	}
+
+	predicate, _ := StringToMSP("(10, " + strings.Join(names, ", ") + ")")
+
+	sec := make([]byte, 20)
+	rand.Read(sec)
+
+	shares, err := predicate.DistributeShares(sec, &db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt a different byte of each of the first 25 users' shares.  That's as
+	// many as can be corrected.
+	sharesDb := Database(shares)
+	for i, name := range names[:25] {
+		bad := append([]byte{}, shares[name][0]...)
+		bad[i%len(bad)] ^= 1
+		sharesDb[name] = [][]byte{bad}
+	}
+
+	_, err = predicate.RecoverSecretRobust(&sharesDb)
+	if ise, ok := err.(*InconsistentSharesError); !ok || !reflect.DeepEqual(ise.Names, names[:25]) {
+		t.Fatalf("Wrong cheaters found: %v", err)
+	}
+
+	// One more is too many, and decoding gives up quickly rather than searching
+	// every subset of shares.
+	bad := append([]byte{}, shares[names[25]][0]...)
+	bad[0] ^= 1
+	sharesDb[names[25]] = [][]byte{bad}
+
+	if _, err := predicate.RecoverSecretRobust(&sharesDb); err != ErrUndecodable {
+		t.Fatalf("Undecodable shares weren't rejected: %v", err)
+	}
+}
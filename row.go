@@ -29,6 +29,17 @@ func (r Row) AddM(s Row) {
 	}
 }
 
+// SubM subtracts s from r.
+func (r Row) SubM(s Row) {
+	if r.Width() != s.Width() {
+		panic("Can't subtract rows that are different sizes!")
+	}
+
+	for i := range s.r {
+		r.r[i].SubM(s.r[i])
+	}
+}
+
 // MulM multiplies the row by a scalar.
 func (r Row) MulM(e Elem) {
 	for i := range r.r {
@@ -36,6 +47,14 @@ func (r Row) MulM(e Elem) {
 	}
 }
 
+// condSwap swaps the values of r and s if swap is 1 and leaves them alone if
+// swap is 0, in constant time.
+func (r Row) condSwap(s Row, swap int) {
+	for i := range r.r {
+		r.r[i].condSwap(s.r[i], swap)
+	}
+}
+
 func (r Row) Mul(e Elem) Row {
 	elem := r.Row(r.Width())
 	for i := range r.r {
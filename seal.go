@@ -0,0 +1,185 @@
+package msp
+
+import (
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// Sealed shares are encrypted to a user's ECDH public key, with P-256 or
+// X25519.  Each user's blob is:
+//
+//	curve (1) || ephemeral public key || nonce (12) || ciphertext
+//
+// where the AES-256-GCM key is derived with HKDF-SHA256 from the shared secret
+// between the ephemeral key and the user's key, salted with both public keys.
+// The user's name is authenticated along with their shares.
+
+// ErrUnseal is returned when sealed shares fail to decrypt:  they were sealed
+// to a different key or user, or have been modified.
+var ErrUnseal = errors.New("Sealed shares failed to decrypt.")
+
+const (
+	sealP256   = 1
+	sealX25519 = 2
+)
+
+var sealCurves = map[byte]ecdh.Curve{
+	sealP256:   ecdh.P256(),
+	sealX25519: ecdh.X25519(),
+}
+
+// SealShares encrypts each user's shares, as returned by DistributeShares, to
+// their public key in keys.
+func SealShares(shares map[string][][]byte, keys map[string]*ecdh.PublicKey) (map[string][]byte, error) {
+	out := make(map[string][]byte, len(shares))
+
+	for name, userShares := range shares {
+		pub, ok := keys[name]
+		if !ok {
+			return nil, fmt.Errorf("No public key for %v.", name)
+		}
+
+		sealed, err := sealShares(name, userShares, pub)
+		if err != nil {
+			return nil, err
+		}
+		out[name] = sealed
+	}
+
+	return out, nil
+}
+
+func sealShares(name string, shares [][]byte, pub *ecdh.PublicKey) ([]byte, error) {
+	id, ok := sealCurveID(pub.Curve())
+	if !ok {
+		return nil, errors.New("Unsupported curve.")
+	}
+
+	ephemeral, err := pub.Curve().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := sealAEAD(ephemeral, pub, ephemeral.PublicKey().Bytes(), pub.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	out := append([]byte{id}, ephemeral.PublicKey().Bytes()...)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out = append(out, nonce...)
+
+	return aead.Seal(out, nonce, marshalShares(shares), []byte(name)), nil
+}
+
+// UnsealShares decrypts the named user's sealed shares with their private key.
+func UnsealShares(name string, sealed []byte, priv *ecdh.PrivateKey) ([][]byte, error) {
+	if len(sealed) < 1 {
+		return nil, errors.New("Sealed shares are too short.")
+	}
+
+	curve, ok := sealCurves[sealed[0]]
+	if !ok {
+		return nil, errors.New("Unsupported curve.")
+	} else if curve != priv.Curve() {
+		return nil, ErrUnseal
+	}
+
+	size := len(priv.PublicKey().Bytes())
+	if len(sealed) < 1+size {
+		return nil, errors.New("Sealed shares are too short.")
+	}
+
+	ephemeral, err := curve.NewPublicKey(sealed[1 : 1+size])
+	if err != nil {
+		return nil, err
+	}
+	sealed = sealed[1+size:]
+
+	aead, err := sealAEAD(priv, ephemeral, ephemeral.Bytes(), priv.PublicKey().Bytes())
+	if err != nil {
+		return nil, ErrUnseal
+	}
+
+	if len(sealed) < aead.NonceSize()+aead.Overhead() {
+		return nil, errors.New("Sealed shares are too short.")
+	}
+	nonce, ct := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+	pt, err := aead.Open(nil, nonce, ct, []byte(name))
+	if err != nil {
+		return nil, ErrUnseal
+	}
+
+	return unmarshalShares(pt)
+}
+
+// sealAEAD derives the AEAD shared by priv and pub, salted with the ephemeral
+// public key followed by the user's.
+func sealAEAD(priv *ecdh.PrivateKey, pub *ecdh.PublicKey, ephemeral, user []byte) (cipher.AEAD, error) {
+	secret, err := priv.ECDH(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := append(append([]byte{}, ephemeral...), user...)
+
+	key, err := hkdf.Key(sha256.New, secret, salt, "msp sealed shares", 32)
+	if err != nil {
+		return nil, err
+	}
+
+	return newHybridAEAD(key)
+}
+
+func sealCurveID(curve ecdh.Curve) (byte, bool) {
+	for id, c := range sealCurves {
+		if c == curve {
+			return id, true
+		}
+	}
+
+	return 0, false
+}
+
+// A SealedDatabase is a UserDatabase of sealed shares, as returned by
+// SealShares.  PrivateKey is only called from GetShare, to get the key to
+// unseal the named user's shares with.
+type SealedDatabase struct {
+	Sealed     map[string][]byte
+	PrivateKey func(name string) (*ecdh.PrivateKey, error)
+}
+
+func (sd *SealedDatabase) ValidUser(name string) bool {
+	_, ok := sd.Sealed[name]
+	return ok
+}
+
+func (sd *SealedDatabase) CanGetShare(name string) bool {
+	_, ok := sd.Sealed[name]
+	return ok
+}
+
+// GetShare gets the user's private key and unseals their shares.
+func (sd *SealedDatabase) GetShare(name string) ([][]byte, error) {
+	sealed, ok := sd.Sealed[name]
+	if !ok {
+		return nil, errors.New("Not found!")
+	}
+
+	priv, err := sd.PrivateKey(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return UnsealShares(name, sealed, priv)
+}
@@ -0,0 +1,68 @@
+package msp
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"testing"
+)
+
+func TestSealShares(t *testing.T) {
+	privs := make(map[string]*ecdh.PrivateKey)
+	pubs := make(map[string]*ecdh.PublicKey)
+	for name, curve := range map[string]ecdh.Curve{"Alice": ecdh.P256(), "Bob": ecdh.X25519(), "Carl": ecdh.X25519()} {
+		priv, err := curve.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		privs[name], pubs[name] = priv, priv.PublicKey()
+	}
+
+	db := &Database{"Alice": nil, "Bob": nil, "Carl": nil}
+	sec := []byte("attack at dawn")
+	predicate, _ := StringToMSP("(2, (1, Alice, Bob), Carl)")
+
+	shares, err := predicate.DistributeShares(sec, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := SealShares(shares, pubs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	asked := 0
+	sd := &SealedDatabase{sealed, func(name string) (*ecdh.PrivateKey, error) {
+		asked++
+		return privs[name], nil
+	}}
+
+	out, err := predicate.RecoverSecret(sd)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(sec, out) {
+		t.Fatalf("Secrets derived differed:  %x %x", sec, out)
+	} else if asked != 2 {
+		t.Fatalf("Asked for %v private keys, not 2.", asked)
+	}
+
+	// Shares don't unseal with the wrong key or name, or once modified.
+	if _, err := UnsealShares("Bob", sealed["Bob"], privs["Carl"]); err != ErrUnseal {
+		t.Fatalf("Wrong key was accepted: %v", err)
+	} else if _, err := UnsealShares("Carl", sealed["Bob"], privs["Bob"]); err != ErrUnseal {
+		t.Fatalf("Wrong name was accepted: %v", err)
+	} else if _, err := UnsealShares("Alice", sealed["Alice"], privs["Bob"]); err != ErrUnseal {
+		t.Fatalf("Wrong curve was accepted: %v", err)
+	}
+
+	sealed["Bob"][len(sealed["Bob"])-1] ^= 1
+	if _, err := UnsealShares("Bob", sealed["Bob"], privs["Bob"]); err != ErrUnseal {
+		t.Fatalf("Modified shares were accepted: %v", err)
+	}
+
+	delete(pubs, "Carl")
+	if _, err := SealShares(shares, pubs); err == nil {
+		t.Fatalf("Missing public key wasn't noticed!")
+	}
+}
@@ -0,0 +1,99 @@
+package msp
+
+import (
+	"crypto/rand"
+	"math"
+	mrand "math/rand"
+	"os"
+	"testing"
+	"time"
+)
+
+// The tests in this file check that field arithmetic takes the same amount of
+// time on random inputs as on sparse ones (zero, one, and single-bit elements),
+// which is what a branching or table-indexing implementation would leak.  It's
+// a Welch's t-test over interleaved measurements, in the style of dudect.
+//
+// Timing measurements are noisy on shared machines, so they only run when
+// MSP_TIMING_TEST is set.
+
+// timingThreshold is the t-statistic above which the two classes are considered
+// distinguishable.
+const timingThreshold = 10
+
+func checkTiming(t *testing.T, name string, field Field, samples int, op func(x Elem)) {
+	if os.Getenv("MSP_TIMING_TEST") == "" {
+		t.Skip("Set MSP_TIMING_TEST to run timing tests.")
+	}
+
+	const batch = 4
+
+	// Prepare inputs ahead of time so generating them isn't measured.
+	inputs, classes := make([]Elem, samples), make([]int, samples)
+	for i := range inputs {
+		inputs[i], classes[i] = field.Zero(), mrand.Intn(2)
+
+		if classes[i] == 0 {
+			rand.Read(inputs[i].e)
+		} else if bit := mrand.Intn(8*field.Size() + 1); bit < 8*field.Size() {
+			inputs[i].e[bit/8] = 1 << uint(bit%8)
+		}
+	}
+
+	var (
+		n    [2]float64
+		mean [2]float64
+		m2   [2]float64
+	)
+
+	for i, x := range inputs {
+		start := time.Now()
+		for j := 0; j < batch; j++ {
+			op(x)
+		}
+		d := float64(time.Since(start))
+
+		// Welford's online mean and variance.
+		c := classes[i]
+		n[c]++
+		delta := d - mean[c]
+		mean[c] += delta / n[c]
+		m2[c] += delta * (d - mean[c])
+	}
+
+	v0, v1 := m2[0]/(n[0]-1), m2[1]/(n[1]-1)
+	tStat := (mean[0] - mean[1]) / math.Sqrt(v0/n[0]+v1/n[1])
+
+	t.Logf("%v: random %.0fns, sparse %.0fns, t = %.2f", name, mean[0]/batch, mean[1]/batch, tStat)
+	if math.Abs(tStat) > timingThreshold {
+		t.Fatalf("%v: timing depends on input (t = %.2f)", name, tStat)
+	}
+}
+
+func TestTimingMul(t *testing.T) {
+	for _, size := range []int{1, 16, 32} {
+		field := Fields[size]
+
+		y := field.Zero()
+		rand.Read(y.e)
+
+		checkTiming(t, "Mul", field, 20000, func(x Elem) { x.Mul(y) })
+	}
+}
+
+func TestTimingInvert(t *testing.T) {
+	for _, size := range []int{1, 16, 32} {
+		checkTiming(t, "Invert", Fields[size], 2000, func(x Elem) { x.Invert() })
+	}
+}
+
+func TestTimingRecovery(t *testing.T) {
+	field := Fields[16]
+
+	checkTiming(t, "Recovery", field, 2000, func(x Elem) {
+		M := field.Vandermonde([]int{0, 1, 2}, 3)
+		M.m[1].r[1] = x
+
+		M.Recovery()
+	})
+}
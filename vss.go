@@ -0,0 +1,338 @@
+package msp
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// Verifiable secret sharing over P-256.  Secrets and shares are elements of
+// P256Field, the integers modulo the order of the group, and the polynomial
+// f(x) = a_0 + a_1 x + ... of each threshold gate is published as commitments
+// to its coefficients.  Anybody can check a share against them without
+// learning anything else about f.
+//
+// Feldman commitments are g^(a_j), and a share is checked with:
+//
+//     g^f(x) = prod_j (g^(a_j))^(x^j)
+//
+// Pedersen commitments are g^(a_j) h^(b_j), where b(x) is a second, random
+// blinding polynomial and nobody knows the discrete log of h.  Each share is
+// the pair (f(x), b(x)), and is checked with:
+//
+//     g^f(x) h^b(x) = prod_j (g^(a_j) h^(b_j))^(x^j)
+//
+// Feldman commitments reveal g^secret, so the secret is only computationally
+// hidden.  Pedersen commitments are perfectly hiding.
+//
+// The secret of a nested threshold gate is a share of the gate above it, so
+// the commitment to its constant term is checked the same way.
+
+var vssCurve = elliptic.P256()
+
+// (vssHx, vssHy) is the second generator used by Pedersen commitments.  It's
+// found by hashing a fixed string to an x-coordinate, so nobody knows its
+// discrete log.
+var vssHx, vssHy = func() (x, y *big.Int) {
+	for i := 0; ; i++ {
+		h := sha256.Sum256([]byte(fmt.Sprintf("msp pedersen generator %v", i)))
+
+		x, y = elliptic.UnmarshalCompressed(vssCurve, append([]byte{2}, h[:]...))
+		if x != nil {
+			return x, y
+		}
+	}
+}()
+
+// ErrInvalidShare is returned when a share isn't consistent with the dealer's
+// commitments.
+var ErrInvalidShare = errors.New("Share doesn't match commitments.")
+
+// Commitments are the public commitments to the polynomials used to split a
+// secret, mirroring the structure of the MSP.
+type Commitments struct {
+	Hiding bool           // True for Pedersen commitments and false for Feldman commitments.
+	Coeffs [][]byte       // Commitment to each coefficient of this gate's polynomial, as compressed points.
+	Conds  []*Commitments // Commitments of each nested threshold gate; nil where the condition is a Name.
+}
+
+// DistributeSharesVerifiable splits a secret like DistributeShares, but also
+// returns Feldman commitments that each party can use to check their shares
+// with VerifyShare.  The secret must be a 32-byte big-endian integer less than
+// the order of P-256, and each share is one too.
+func (m MSP) DistributeSharesVerifiable(sec []byte, db UserDatabase) (map[string][][]byte, *Commitments, error) {
+	c := &Commitments{}
+
+	shares, err := m.distribute(sec, db, vssSplitter{rand.Reader, c})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return shares, c, nil
+}
+
+// DistributeSharesPedersen is the same as DistributeSharesVerifiable, but
+// returns Pedersen commitments, which hide the secret perfectly.  Each share is
+// 64 bytes:  the 32-byte secret share followed by the 32-byte blinding share.
+func (m MSP) DistributeSharesPedersen(sec []byte, db UserDatabase) (map[string][][]byte, *Commitments, error) {
+	if _, err := vssElems(sec, 1); err != nil {
+		return nil, nil, err
+	}
+
+	blind, err := P256Field.random(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := &Commitments{Hiding: true}
+
+	shares, err := m.distribute(append(append([]byte{}, sec...), blind...), db, vssSplitter{rand.Reader, c})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return shares, c, nil
+}
+
+// RecoverSecretVerifiable recovers a secret split by DistributeSharesVerifiable
+// or DistributeSharesPedersen, checking every share it uses against the
+// commitments.  ErrInvalidShare is returned if any of them are wrong.
+func (m MSP) RecoverSecretVerifiable(db UserDatabase, c *Commitments) ([]byte, error) {
+	if err := c.check(m); err != nil {
+		return nil, err
+	}
+
+	cache := make(map[string][][]byte, 0)
+
+	sec, err := m.recoverSecret(db, cache, vssCombiner{c})
+	if err != nil {
+		return nil, err
+	}
+
+	return sec[:32], nil // Drop the blinding factor of Pedersen secrets.
+}
+
+// VerifyShare checks the share at the given index of the named user's shares
+// against the commitments, and that the commitments of every threshold gate
+// between the share and the top of the MSP are consistent with each other.
+func (m MSP) VerifyShare(name string, index int, share []byte, c *Commitments) error {
+	found, err := m.verifyShare(Name{name, index}, share, c)
+	if err != nil {
+		return err
+	} else if !found {
+		return errors.New("Share isn't in the predicate.")
+	}
+
+	return nil
+}
+
+func (m MSP) verifyShare(leaf Name, share []byte, c *Commitments) (bool, error) {
+	if err := c.check(m); err != nil {
+		return false, err
+	}
+
+	for i, cond := range m.Conds {
+		switch cond := cond.(type) {
+		case Name:
+			if cond == leaf {
+				return true, c.verify(i, share)
+			}
+
+		case Formatted:
+			found, err := MSP(cond).verifyShare(leaf, share, c.Conds[i])
+			if err != nil {
+				return found, err
+			} else if found {
+				return true, c.verifyPoint(i, c.Conds[i].Coeffs[0])
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// check makes sure the commitments have the same shape as the MSP's top-level
+// threshold gate.
+func (c *Commitments) check(m MSP) error {
+	if c == nil || len(c.Coeffs) != m.Min || len(c.Conds) != len(m.Conds) {
+		return errors.New("Commitments don't match the predicate.")
+	}
+
+	for i, cond := range m.Conds {
+		if _, ok := cond.(Formatted); ok && (c.Conds[i] == nil || c.Conds[i].Hiding != c.Hiding) {
+			return errors.New("Commitments don't match the predicate.")
+		}
+	}
+
+	return nil
+}
+
+// scalars returns the number of scalars in each share:  one for Feldman
+// commitments, or two for Pedersen commitments.
+func (c *Commitments) scalars() int {
+	if c.Hiding {
+		return 2
+	}
+
+	return 1
+}
+
+// commit returns the commitment to a secret, or to a secret and its blinding
+// factor.
+func (c *Commitments) commit(s []Elem) []byte {
+	x, y := vssCurve.ScalarBaseMult(s[0].e)
+	if c.Hiding {
+		hx, hy := vssCurve.ScalarMult(vssHx, vssHy, s[1].e)
+		x, y = vssCurve.Add(x, y, hx, hy)
+	}
+
+	return encodePoint(x, y)
+}
+
+// verify checks the share of the ith condition against the commitments.
+func (c *Commitments) verify(i int, share []byte) error {
+	s, err := vssElems(share, c.scalars())
+	if err != nil {
+		return err
+	}
+
+	return c.verifyPoint(i, c.commit(s))
+}
+
+// verifyPoint checks that point is the commitment to the polynomial evaluated
+// at the ith condition's point.
+func (c *Commitments) verifyPoint(i int, point []byte) error {
+	x := P256Field.Point(i).e
+
+	// Horner's rule in the exponent.
+	accX, accY, err := decodePoint(c.Coeffs[len(c.Coeffs)-1])
+	if err != nil {
+		return err
+	}
+
+	for j := len(c.Coeffs) - 2; j >= 0; j-- {
+		cx, cy, err := decodePoint(c.Coeffs[j])
+		if err != nil {
+			return err
+		}
+
+		accX, accY = vssCurve.ScalarMult(accX, accY, x)
+		accX, accY = vssCurve.Add(accX, accY, cx, cy)
+	}
+
+	if string(encodePoint(accX, accY)) != string(point) {
+		return ErrInvalidShare
+	}
+
+	return nil
+}
+
+// vssSplitter splits secrets with Shamir's scheme over P256Field, and records
+// commitments to each polynomial.
+type vssSplitter struct {
+	random io.Reader
+	c      *Commitments
+}
+
+func (vs vssSplitter) Split(m MSP, sec []byte) ([][]byte, error) {
+	if _, err := vssElems(sec, vs.c.scalars()); err != nil {
+		return nil, err
+	}
+
+	shares, coeffs, err := m.splitBlocks(P256Field, vs.random, sec)
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit to the jth coefficient of every polynomial at once.
+	vs.c.Coeffs = make([][]byte, m.Min)
+	for j := range vs.c.Coeffs {
+		coeff := make([]Elem, len(coeffs))
+		for l := range coeffs {
+			coeff[l] = coeffs[l].r[j]
+		}
+
+		vs.c.Coeffs[j] = vs.c.commit(coeff)
+	}
+	vs.c.Conds = make([]*Commitments, len(m.Conds))
+
+	return shares, nil
+}
+
+func (vs vssSplitter) Child(i int) splitter {
+	vs.c.Conds[i] = &Commitments{Hiding: vs.c.Hiding}
+	return vssSplitter{vs.random, vs.c.Conds[i]}
+}
+
+// vssCombiner recovers secrets split by vssSplitter.
+type vssCombiner struct {
+	c *Commitments
+}
+
+func (vc vssCombiner) Combine(m MSP, locs []int, shares [][]byte) ([]byte, error) {
+	if err := vc.c.check(m); err != nil {
+		return nil, err
+	}
+
+	for i, loc := range locs {
+		if err := vc.c.verify(loc, shares[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return fieldCombiner{P256Field}.Combine(m, locs, shares)
+}
+
+// Child returns a combiner with nil commitments if the gate's commitments are
+// missing or have the wrong shape, so the nested gate fails its check instead of
+// panicking.
+func (vc vssCombiner) Child(i int) combiner {
+	if vc.c == nil || i < 0 || i >= len(vc.c.Conds) {
+		return vssCombiner{nil}
+	}
+
+	return vssCombiner{vc.c.Conds[i]}
+}
+
+// vssElems parses count elements of P256Field.
+func vssElems(b []byte, count int) ([]Elem, error) {
+	if len(b) != P256Field.Size()*count {
+		return nil, fmt.Errorf("Secret must be %v bytes.", P256Field.Size()*count)
+	} else if err := checkElems(P256Field, b); err != nil {
+		return nil, err
+	}
+
+	out := make([]Elem, count)
+	for i := range out {
+		out[i] = P256Field.Elem(b[P256Field.Size()*i : P256Field.Size()*(i+1)])
+	}
+
+	return out, nil
+}
+
+// encodePoint returns the compressed encoding of a point, or a single zero byte
+// for the point at infinity.
+func encodePoint(x, y *big.Int) []byte {
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return []byte{0}
+	}
+
+	return elliptic.MarshalCompressed(vssCurve, x, y)
+}
+
+func decodePoint(b []byte) (x, y *big.Int, err error) {
+	if len(b) == 1 && b[0] == 0 {
+		return new(big.Int), new(big.Int), nil
+	}
+
+	x, y = elliptic.UnmarshalCompressed(vssCurve, b)
+	if x == nil {
+		return nil, nil, errors.New("Invalid commitment.")
+	}
+
+	return x, y, nil
+}
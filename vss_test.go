@@ -0,0 +1,222 @@
+package msp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestVerifiable(t *testing.T) {
+	db := &Database{
+		"Alice": [][]byte{},
+		"Bob":   [][]byte{},
+		"Carl":  [][]byte{},
+		"Dave":  [][]byte{},
+	}
+
+	sec := make([]byte, 32)
+	rand.Read(sec)
+	sec[0] &= 127 // Makes sure the secret is less than the order of the group.
+
+	predicate, _ := StringToMSP("(2, (1, Alice, Bob), (2, Carl, Dave, Alice), Carl)")
+
+	shares, c, err := predicate.DistributeSharesVerifiable(sec, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, userShares := range shares {
+		for index, share := range userShares {
+			if err := predicate.VerifyShare(name, index, share, c); err != nil {
+				t.Fatalf("%v's share #%v didn't verify: %v", name, index, err)
+			}
+		}
+	}
+
+	// Shares must not verify for somebody else or after being modified.
+	if err := predicate.VerifyShare("Dave", 0, shares["Alice"][1], c); err != ErrInvalidShare {
+		t.Fatalf("Alice's share verified as Dave's: %v", err)
+	}
+
+	bad := make([]byte, 32)
+	copy(bad, shares["Carl"][1])
+	bad[31] ^= 1
+
+	if err := predicate.VerifyShare("Carl", 1, bad, c); err != ErrInvalidShare {
+		t.Fatalf("Modified share verified: %v", err)
+	}
+
+	if err := predicate.VerifyShare("Eve", 0, shares["Alice"][0], c); err == nil {
+		t.Fatalf("Share for a user not in the predicate verified!")
+	}
+
+	// Recover the secret.
+	sharesDb := Database(shares)
+	out, err := predicate.RecoverSecretVerifiable(&sharesDb, c)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(sec, out) {
+		t.Fatalf("Secrets derived differed:  %x %x", sec, out)
+	}
+
+	sharesDb["Carl"] = [][]byte{shares["Carl"][0], bad}
+	if _, err := predicate.RecoverSecretVerifiable(&sharesDb, c); err != ErrInvalidShare {
+		t.Fatalf("Recovery with a modified share didn't fail: %v", err)
+	}
+}
+
+func TestVerifiableNestedCommitments(t *testing.T) {
+	db := &Database{
+		"Alice": [][]byte{},
+		"Bob":   [][]byte{},
+		"Carl":  [][]byte{},
+	}
+
+	predicate, _ := StringToMSP("(2, (2, Alice, Bob), Carl)")
+
+	shares, c, err := predicate.DistributeSharesVerifiable(make([]byte, 32), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replace the nested gate's commitments and Alice's share with those of a
+	// different split.  Alice's share is consistent with the new commitments,
+	// but they're inconsistent with the top-level gate.
+	sec := make([]byte, 32)
+	sec[31] = 1
+
+	otherShares, other, err := MSP(predicate.Conds[0].(Formatted)).DistributeSharesVerifiable(sec, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Conds[0] = other
+
+	if err := MSP(predicate.Conds[0].(Formatted)).VerifyShare("Alice", 0, otherShares["Alice"][0], other); err != nil {
+		t.Fatalf("Alice's share didn't verify against the nested gate: %v", err)
+	}
+
+	if err := predicate.VerifyShare("Alice", 0, otherShares["Alice"][0], c); err != ErrInvalidShare {
+		t.Fatalf("Inconsistent nested commitments verified: %v", err)
+	}
+
+	if err := predicate.VerifyShare("Carl", 0, shares["Carl"][0], c); err != nil {
+		t.Fatalf("Carl's share didn't verify: %v", err)
+	}
+}
+
+func TestVerifiableBadCommitments(t *testing.T) {
+	db := &Database{
+		"Alice": [][]byte{},
+		"Bob":   [][]byte{},
+		"Carl":  [][]byte{},
+		"Dave":  [][]byte{},
+	}
+
+	predicate, _ := StringToMSP("(2, (1, (2, Alice, Bob), Dave), Carl)")
+
+	shares, c, err := predicate.DistributeSharesVerifiable(make([]byte, 32), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sharesDb := Database(shares)
+
+	// Each case damages a shallow copy of the commitments.
+	cases := map[string]func(c *Commitments) *Commitments{
+		"nil":   func(c *Commitments) *Commitments { return nil },
+		"empty": func(c *Commitments) *Commitments { return &Commitments{} },
+		"missing conditions": func(c *Commitments) *Commitments {
+			c.Conds = nil
+			return c
+		},
+		"nil nested gate": func(c *Commitments) *Commitments {
+			c.Conds = []*Commitments{nil, nil}
+			return c
+		},
+		"empty nested gate": func(c *Commitments) *Commitments {
+			c.Conds = []*Commitments{{}, nil}
+			return c
+		},
+		"wrong depth": func(c *Commitments) *Commitments {
+			nested := *c.Conds[0]
+			nested.Conds = nil
+			c.Conds = []*Commitments{&nested, nil}
+			return c
+		},
+	}
+
+	for name, damage := range cases {
+		copied := *c
+		if _, err := predicate.RecoverSecretVerifiable(&sharesDb, damage(&copied)); err == nil {
+			t.Fatalf("Recovery with %v commitments succeeded!", name)
+		}
+	}
+
+	if _, err := predicate.RecoverSecretVerifiable(&sharesDb, c); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifiableBadSecret(t *testing.T) {
+	db := &Database{"Alice": [][]byte{}, "Bob": [][]byte{}}
+	predicate, _ := StringToMSP("Alice & Bob")
+
+	for _, sec := range [][]byte{make([]byte, 16), bytes.Repeat([]byte{0xff}, 32)} {
+		if _, _, err := predicate.DistributeSharesVerifiable(sec, db); err == nil {
+			t.Fatalf("Invalid secret was accepted: %x", sec)
+		}
+	}
+}
+
+func TestPedersen(t *testing.T) {
+	db := &Database{
+		"Alice": [][]byte{},
+		"Bob":   [][]byte{},
+		"Carl":  [][]byte{},
+	}
+
+	sec := make([]byte, 32)
+	rand.Read(sec)
+	sec[0] &= 127
+
+	predicate, _ := StringToMSP("(2, (2, Alice, Bob, Carl), Carl, Alice)")
+
+	shares, c, err := predicate.DistributeSharesPedersen(sec, db)
+	if err != nil {
+		t.Fatal(err)
+	} else if !c.Hiding {
+		t.Fatalf("Pedersen commitments aren't marked as hiding!")
+	}
+
+	for name, userShares := range shares {
+		for index, share := range userShares {
+			if len(share) != 64 {
+				t.Fatalf("%v's share #%v is %v bytes, not 64.", name, index, len(share))
+			} else if err := predicate.VerifyShare(name, index, share, c); err != nil {
+				t.Fatalf("%v's share #%v didn't verify: %v", name, index, err)
+			}
+		}
+	}
+
+	// Modifying either half of a share must be caught.
+	for _, i := range []int{0, 63} {
+		bad := append([]byte{}, shares["Bob"][0]...)
+		bad[i] ^= 1
+
+		if err := predicate.VerifyShare("Bob", 0, bad, c); err != ErrInvalidShare {
+			t.Fatalf("Modified share verified: %v", err)
+		}
+	}
+
+	// A Feldman share with the right secret half isn't a valid Pedersen share.
+	if err := predicate.VerifyShare("Bob", 0, shares["Bob"][0][:32], c); err == nil {
+		t.Fatalf("Share without a blinding factor verified!")
+	}
+
+	sharesDb := Database(shares)
+	out, err := predicate.RecoverSecretVerifiable(&sharesDb, c)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(sec, out) {
+		t.Fatalf("Secrets derived differed:  %x %x", sec, out)
+	}
+}